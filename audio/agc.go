@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// Default AGC behaviour: rise quickly with the music, relax slowly in quiet passages
+	defaultAGCTarget  = 0.25
+	defaultAGCAttack  = 0.5
+	defaultAGCRelease = 5.0
+	defaultAGCMinGain = 0.5
+	defaultAGCMaxGain = 40.0
+
+	// Envelopes below this are treated as silence and do not raise the gain further
+	agcNoiseFloor = 0.0005
+)
+
+// AGC normalizes levels by tracking a rolling loudness envelope
+type AGC struct {
+	target   float64 // RMS level the envelope is normalized to
+	attack   float64 // Seconds to follow rising loudness
+	release  float64 // Seconds to follow falling loudness
+	minGain  float64
+	maxGain  float64
+	envelope float64
+	gain     float64
+}
+
+// NewAGC creates an AGC normalizing to the target RMS level with attack/release times in seconds
+func NewAGC(target, attack, release float64) *AGC {
+	return &AGC{
+		target:  target,
+		attack:  attack,
+		release: release,
+		minGain: defaultAGCMinGain,
+		maxGain: defaultAGCMaxGain,
+		gain:    1.0,
+	}
+}
+
+// SetGainRange limits how far the AGC may attenuate or boost
+func (a *AGC) SetGainRange(minGain, maxGain float64) {
+	if minGain > 0 && maxGain >= minGain {
+		a.minGain = minGain
+		a.maxGain = maxGain
+	}
+}
+
+// Process feeds the RMS level of a buffer lasting dt seconds and returns the gain to apply
+func (a *AGC) Process(rms, dt float64) float64 {
+	tau := a.release
+	if rms > a.envelope {
+		tau = a.attack
+	}
+	a.envelope += (rms - a.envelope) * (1 - math.Exp(-dt/tau))
+
+	// Hold the gain through silence instead of boosting the noise floor
+	if a.envelope < agcNoiseFloor {
+		return a.gain
+	}
+
+	a.gain = math.Max(a.minGain, math.Min(a.target/a.envelope, a.maxGain))
+	return a.gain
+}
+
+// Gain returns the current gain
+func (a *AGC) Gain() float64 {
+	return a.gain
+}
+
+// Envelope returns the tracked loudness envelope
+func (a *AGC) Envelope() float64 {
+	return a.envelope
+}
+
+// Reset forgets the envelope, e.g. after switching inputs
+func (a *AGC) Reset() {
+	a.envelope = 0
+	a.gain = 1.0
+}
@@ -0,0 +1,53 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAGCAttackRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to float64
+		tau      float64 // Time constant the envelope should follow the step with
+	}{
+		{"attack", 0.01, 0.2, defaultAGCAttack},
+		{"release", 0.2, 0.01, defaultAGCRelease},
+	}
+	for _, test := range tests {
+		agc := NewAGC(defaultAGCTarget, defaultAGCAttack, defaultAGCRelease)
+		agc.Process(test.from, 1000)
+
+		// After one time constant the envelope has covered 1 - 1/e of the step
+		const dt = 0.01
+		for elapsed := 0.0; elapsed < test.tau-dt/2; elapsed += dt {
+			agc.Process(test.to, dt)
+		}
+		want := test.from + (test.to-test.from)*(1-1/math.E)
+		if math.Abs(agc.Envelope()-want) > 0.01*math.Abs(test.to-test.from) {
+			t.Errorf("%s: envelope after %vs = %.4f, want %.4f", test.name, test.tau, agc.Envelope(), want)
+		}
+		if wantGain := defaultAGCTarget / agc.Envelope(); math.Abs(agc.Gain()-wantGain) > 1e-9 {
+			t.Errorf("%s: gain = %.3f, want %.3f", test.name, agc.Gain(), wantGain)
+		}
+	}
+}
+
+func TestAGCGainLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		rms  float64
+		want float64
+	}{
+		{"normalizes", 0.1, defaultAGCTarget / 0.1},
+		{"boost capped", 0.001, defaultAGCMaxGain},
+		{"cut capped", 0.9, defaultAGCMinGain},
+		{"silence holds the gain", 0, 1.0},
+	}
+	for _, test := range tests {
+		agc := NewAGC(defaultAGCTarget, defaultAGCAttack, defaultAGCRelease)
+		if got := agc.Process(test.rms, 1000); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: gain = %.3f, want %.3f", test.name, got, test.want)
+		}
+	}
+}
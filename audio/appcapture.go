@@ -0,0 +1,211 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Null sink the captured application plays into; we record its monitor
+	captureSinkName = "milkshaker_capture"
+
+	// Latency of the loopback that keeps the captured application audible
+	captureLoopbackLatencyMs = 30
+)
+
+// appCapture tracks the routing set up to capture a single application
+type appCapture struct {
+	modules     []int          // Null sink and loopback, in load order
+	movedInputs map[int]string // Sink input index -> sink it was playing to
+}
+
+// SetApp selects an application to capture instead of the whole output
+func (p *Player) SetApp(name string) {
+	p.appName = name
+}
+
+// GetApp returns the captured application, or "" when capturing the whole output
+func (p *Player) GetApp() string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.capturedApp
+}
+
+// ListApps returns the names of applications currently playing audio
+func (p *Player) ListApps() ([]string, error) {
+	inputs, err := p.server.ListSinkInputs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list playing applications: %v", err)
+	}
+
+	var apps []string
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		if input.App == "" || seen[input.App] {
+			continue
+		}
+		seen[input.App] = true
+		apps = append(apps, input.App)
+	}
+	return apps, nil
+}
+
+// CaptureApp switches capture to the named application, or back to the whole output for ""
+func (p *Player) CaptureApp(name string) error {
+	p.captureMutex.Lock()
+	defer p.captureMutex.Unlock()
+	return p.switchApp(name)
+}
+
+// CycleApp captures the next playing application, returning to the whole output after the last one
+func (p *Player) CycleApp() (string, error) {
+	// Hold the capture mutex from listing to capturing, so quick presses move on one
+	// application each instead of racing to capture the same one
+	p.captureMutex.Lock()
+	defer p.captureMutex.Unlock()
+
+	apps, err := p.ListApps()
+	if err != nil {
+		return "", err
+	}
+
+	current := p.GetApp()
+	next := ""
+	if current == "" && len(apps) > 0 {
+		next = apps[0]
+	}
+	for i, app := range apps {
+		if app == current && i+1 < len(apps) {
+			next = apps[i+1]
+		}
+	}
+
+	return next, p.switchApp(next)
+}
+
+// switchApp captures the named application, or the whole output for ""; the caller
+// must hold the capture mutex
+func (p *Player) switchApp(name string) error {
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
+	if !p.routingEnabled || p.externalSource {
+		return fmt.Errorf("application capture needs sound server routing")
+	}
+
+	if name == "" {
+		p.releaseApp()
+		p.monitorSource = p.setupCurrentAudioMonitor()
+		return nil
+	}
+
+	monitorSource, err := p.captureApp(name)
+	if err != nil {
+		return err
+	}
+	p.monitorSource = monitorSource
+	return nil
+}
+
+// captureApp moves the streams of the first application matching name into a
+// dedicated null sink, loops them back to their sink so they stay audible and
+// makes the null sink's monitor the default source. Returns that monitor source
+func (p *Player) captureApp(name string) (string, error) {
+	apps, err := p.ListApps()
+	if err != nil {
+		return "", err
+	}
+	var app string
+	for _, candidate := range apps {
+		if strings.Contains(strings.ToLower(candidate), strings.ToLower(name)) {
+			app = candidate
+			break
+		}
+	}
+	if app == "" {
+		return "", fmt.Errorf("no application playing audio matches %q", name)
+	}
+
+	// Undo a previous application capture first, then look at the streams again:
+	// a captured application was just moved back from the capture sink
+	p.releaseApp()
+
+	inputs, err := p.server.ListSinkInputs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list playing applications: %v", err)
+	}
+	var matches []SinkInputInfo
+	for _, input := range inputs {
+		if input.App == app {
+			matches = append(matches, input)
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%s stopped playing audio", app)
+	}
+
+	sinks, err := p.server.ListSinks()
+	if err != nil {
+		return "", fmt.Errorf("failed to list sinks: %v", err)
+	}
+	sinkNames := make(map[int]string, len(sinks))
+	for _, sink := range sinks {
+		sinkNames[sink.Index] = sink.Name
+	}
+
+	nullSink, err := p.server.LoadModule("module-null-sink",
+		"sink_name="+captureSinkName,
+		"sink_properties=device.description=Milkshaker")
+	if err != nil {
+		return "", fmt.Errorf("failed to create capture sink: %v", err)
+	}
+	p.app = appCapture{modules: []int{nullSink}, movedInputs: make(map[int]string)}
+
+	monitorSource := captureSinkName + ".monitor"
+
+	// Keep the application audible where it was playing
+	if original := sinkNames[matches[0].Sink]; original != "" {
+		loopback, err := p.server.LoadModule("module-loopback",
+			"source="+monitorSource,
+			"sink="+original,
+			fmt.Sprintf("latency_msec=%d", captureLoopbackLatencyMs))
+		if err == nil {
+			p.app.modules = append(p.app.modules, loopback)
+		}
+	}
+
+	for _, input := range matches {
+		if err := p.server.MoveSinkInput(input.Index, captureSinkName); err != nil {
+			continue
+		}
+		if original := sinkNames[input.Sink]; original != "" {
+			p.app.movedInputs[input.Index] = original
+		}
+	}
+
+	if err := p.setDefaultSource(monitorSource); err != nil {
+		p.releaseApp()
+		return "", fmt.Errorf("failed to select capture sink: %v", err)
+	}
+	p.forceMonitorSource(monitorSource)
+
+	p.mutex.Lock()
+	p.capturedApp = app
+	p.mutex.Unlock()
+	return monitorSource, nil
+}
+
+// releaseApp moves the captured application back and removes the capture sink
+func (p *Player) releaseApp() {
+	for index, sink := range p.app.movedInputs {
+		p.server.MoveSinkInput(index, sink)
+	}
+	for i := len(p.app.modules) - 1; i >= 0; i-- {
+		p.server.UnloadModule(p.app.modules[i])
+	}
+	p.app = appCapture{}
+
+	p.mutex.Lock()
+	p.capturedApp = ""
+	p.mutex.Unlock()
+}
@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Largest A/V offset accepted in either direction
+	maxAVOffset = 2 * time.Second
+
+	// Frames kept beyond the A/V offset, so a frame at or before the display time exists
+	historySlack = 250 * time.Millisecond
+
+	// Weight of each new buffer in the smoothed input latency
+	latencySmoothing = 0.1
+)
+
+// SetAVOffset sets how much later the visuals should follow the captured audio, e.g. to
+// match Bluetooth speakers. Positive offsets show frames that were captured that long ago;
+// negative offsets can only run the beat phase ahead, since beats cannot be shown before
+// they are captured
+func (p *Player) SetAVOffset(offset time.Duration) {
+	if offset > maxAVOffset {
+		offset = maxAVOffset
+	} else if offset < -maxAVOffset {
+		offset = -maxAVOffset
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.avOffset = offset
+	if offset <= 0 {
+		p.history = nil
+	}
+}
+
+// AdjustAVOffset changes the A/V offset by delta and returns the new offset
+func (p *Player) AdjustAVOffset(delta time.Duration) time.Duration {
+	p.SetAVOffset(p.GetAVOffset() + delta)
+	return p.GetAVOffset()
+}
+
+// GetAVOffset returns the A/V offset
+func (p *Player) GetAVOffset() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.avOffset
+}
+
+// GetInputLatency returns how long buffers take from capture to analysis, smoothed over
+// recent buffers; 0 for sources without capture latency
+func (p *Player) GetInputLatency() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.inputLatency
+}
+
+// measureLatency folds the age of a buffer into the input latency; the caller must hold the mutex
+func (p *Player) measureLatency(age time.Duration) {
+	if age < 0 {
+		age = 0
+	}
+	if p.inputLatency == 0 {
+		p.inputLatency = age
+		return
+	}
+	p.inputLatency += time.Duration(latencySmoothing * float64(age-p.inputLatency))
+}
+
+// resetLatency starts measuring the input latency of a new source from its reported value;
+// the caller must hold the mutex
+func (p *Player) resetLatency(source Source) {
+	p.inputLatency = 0
+	if reporter, ok := source.(latencyReporter); ok {
+		p.inputLatency = reporter.InputLatency()
+	}
+	p.history = nil
+}
+
+// rememberFrame keeps an analyzed frame for delayed display and forgets frames older than
+// the A/V offset needs; the caller must hold the mutex
+func (p *Player) rememberFrame(frame AudioFrame) {
+	p.history = append(p.history, frame)
+
+	cutoff := frame.Timestamp.Add(-p.avOffset - historySlack)
+	drop := 0
+	for drop < len(p.history)-1 && p.history[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	p.history = p.history[drop:]
+}
+
+// delayedFrame returns the latest remembered frame captured at or before the display time,
+// or the oldest one while the history is still filling. Each beat is reported in exactly
+// one frame; the caller must hold the mutex and ensure the history is not empty
+func (p *Player) delayedFrame(display time.Time) AudioFrame {
+	index := sort.Search(len(p.history), func(i int) bool {
+		return p.history[i].Timestamp.After(display)
+	}) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	frame := p.history[index]
+	frame.Beat, frame.BeatStrength = false, 0
+	for _, earlier := range p.history[:index+1] {
+		if earlier.Beat && earlier.Timestamp.After(p.lastShown) {
+			frame.Beat = true
+			frame.BeatStrength = math.Max(frame.BeatStrength, earlier.BeatStrength)
+		}
+	}
+	p.lastShown = frame.Timestamp
+	return frame
+}
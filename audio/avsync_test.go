@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayedFrameKeepsItsWaveform(t *testing.T) {
+	p := NewPlayer()
+	p.SetAGCEnabled(false)
+	p.SetAVOffset(time.Second)
+
+	// Loud buffers until 500 ms ago, silence since; the margin covers slow processing
+	now := time.Now()
+	bufferDuration := time.Second * defaultFramesPerBuffer / defaultSampleRate
+	for ts := now.Add(-1200 * time.Millisecond); ts.Before(now); ts = ts.Add(bufferDuration) {
+		level := float32(0.5)
+		if ts.After(now.Add(-500 * time.Millisecond)) {
+			level = 0
+		}
+		buffer := make([]float32, defaultFramesPerBuffer)
+		for i := range buffer {
+			buffer[i] = level
+		}
+		p.ProcessBuffer([][]float32{buffer}, ts)
+	}
+
+	shown := time.Now()
+	frame := p.GetAudioFrame()
+	if age := shown.Sub(frame.Timestamp); age < time.Second {
+		t.Fatalf("frame captured %v before it was shown, want at least the 1 s offset", age)
+	}
+	if frame.Peak == 0 {
+		t.Fatal("peak = 0, want the audio captured before the offset")
+	}
+	for i, sample := range frame.Waveform {
+		if sample != 0.5 {
+			t.Fatalf("waveform[%d] = %v, want 0.5 from the buffer the levels came from", i, sample)
+		}
+	}
+}
+
+func TestDelayedFrameReportsBeatOnce(t *testing.T) {
+	p := NewPlayer()
+	p.SetAVOffset(100 * time.Millisecond)
+	start := time.Now().Add(-time.Second)
+	for i := 0; i < 5; i++ {
+		p.history = append(p.history, AudioFrame{
+			Timestamp:    start.Add(time.Duration(i) * 10 * time.Millisecond),
+			Beat:         i == 1,
+			BeatStrength: 0.7,
+		})
+	}
+
+	if frame := p.delayedFrame(start.Add(25 * time.Millisecond)); !frame.Beat || frame.BeatStrength != 0.7 {
+		t.Errorf("beat = %v (%v), want the beat at 10 ms", frame.Beat, frame.BeatStrength)
+	}
+	if frame := p.delayedFrame(start.Add(45 * time.Millisecond)); frame.Beat {
+		t.Error("beat reported twice")
+	}
+}
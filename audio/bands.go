@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// Names of the default frequency bands
+const (
+	BandSubBass = "sub-bass"
+	BandBass    = "bass"
+	BandMids    = "mids"
+	BandHighs   = "highs"
+)
+
+// Band describes a named frequency range in Hz
+type Band struct {
+	Name string
+	Low  float64
+	High float64
+}
+
+// DefaultBands returns sub-bass, bass, mids and highs with common crossover points
+func DefaultBands() []Band {
+	bands, _ := BandsFromCrossovers(60, 250, 4000)
+	return bands
+}
+
+// BandsFromCrossovers builds the named bands from the three crossover frequencies between them
+func BandsFromCrossovers(subBass, bass, mids float64) ([]Band, error) {
+	if subBass <= 20 || bass <= subBass || mids <= bass || mids >= 20000 {
+		return nil, fmt.Errorf("crossovers must be increasing between 20 Hz and 20 kHz (got %.0f, %.0f, %.0f)", subBass, bass, mids)
+	}
+
+	return []Band{
+		{Name: BandSubBass, Low: 20, High: subBass},
+		{Name: BandBass, Low: subBass, High: bass},
+		{Name: BandMids, Low: bass, High: mids},
+		{Name: BandHighs, Low: mids, High: 20000},
+	}, nil
+}
+
+// BandAnalyzer tracks smoothed energy in a set of frequency bands
+type BandAnalyzer struct {
+	bands    []Band
+	energies []float64
+	attack   float64 // Smoothing coefficient while energy rises
+	release  float64 // Smoothing coefficient while energy falls
+}
+
+// NewBandAnalyzer creates a band analyzer for the given bands
+func NewBandAnalyzer(bands []Band) *BandAnalyzer {
+	return &BandAnalyzer{
+		bands:    bands,
+		energies: make([]float64, len(bands)),
+		attack:   0.6,
+		release:  0.15,
+	}
+}
+
+// Process updates band energies from linear FFT magnitudes, applying gain before smoothing
+func (b *BandAnalyzer) Process(magnitudes []float64, binHz, gain float64) {
+	for i, band := range b.bands {
+		lo := int(math.Ceil(band.Low / binHz))
+		hi := int(math.Floor(band.High / binHz))
+		if lo < 1 {
+			lo = 1 // Skip DC
+		}
+		if hi >= len(magnitudes) {
+			hi = len(magnitudes) - 1
+		}
+
+		// Band energy is the root of summed power, so a pure tone reads near its amplitude
+		power := 0.0
+		for bin := lo; bin <= hi; bin++ {
+			power += magnitudes[bin] * magnitudes[bin]
+		}
+		target := math.Min(math.Sqrt(power)*gain, 1.0)
+
+		coefficient := b.release
+		if target > b.energies[i] {
+			coefficient = b.attack
+		}
+		b.energies[i] += (target - b.energies[i]) * coefficient
+	}
+}
+
+// Bands returns the configured bands
+func (b *BandAnalyzer) Bands() []Band {
+	return b.bands
+}
+
+// Energies returns the smoothed energy per band (not a copy)
+func (b *BandAnalyzer) Energies() []float64 {
+	return b.energies
+}
@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// BeatEvent describes a detected onset
+type BeatEvent struct {
+	Time     time.Time
+	Strength float64 // 0.0 right at the adaptive threshold, approaching 1.0 for very strong onsets
+}
+
+// BeatDetector finds onsets using spectral flux with an adaptive threshold
+type BeatDetector struct {
+	previous    []float64
+	fluxHistory []float64
+	historySize int
+	threshold   float64 // Standard deviations above the mean flux needed for an onset
+	minFlux     float64 // Absolute floor so background hiss never triggers
+	minInterval time.Duration
+	lastBeat    time.Time
+	flux        float64
+}
+
+// NewBeatDetector creates a detector that adapts over historySize analysis frames
+func NewBeatDetector(historySize int) *BeatDetector {
+	return &BeatDetector{
+		historySize: historySize,
+		threshold:   1.5,
+		minFlux:     0.5,
+		minInterval: 200 * time.Millisecond,
+	}
+}
+
+// SetThreshold sets how many standard deviations above the mean flux an onset must be
+func (d *BeatDetector) SetThreshold(threshold float64) {
+	if threshold > 0 {
+		d.threshold = threshold
+	}
+}
+
+// SetMinInterval sets the shortest allowed gap between two beats
+func (d *BeatDetector) SetMinInterval(interval time.Duration) {
+	d.minInterval = interval
+}
+
+// Process computes spectral flux for new FFT magnitudes and reports whether an onset occurred
+func (d *BeatDetector) Process(magnitudes []float64, now time.Time) (BeatEvent, bool) {
+	if len(d.previous) != len(magnitudes) {
+		d.previous = make([]float64, len(magnitudes))
+	}
+
+	// Spectral flux: summed positive change of log-compressed magnitudes
+	flux := 0.0
+	for i, magnitude := range magnitudes {
+		compressed := math.Log1p(100 * magnitude)
+		if diff := compressed - d.previous[i]; diff > 0 {
+			flux += diff
+		}
+		d.previous[i] = compressed
+	}
+	d.flux = flux
+
+	// Adaptive threshold from the recent flux history
+	mean, stdDev := meanStdDev(d.fluxHistory)
+	threshold := math.Max(mean+d.threshold*stdDev, d.minFlux)
+
+	d.fluxHistory = append(d.fluxHistory, flux)
+	if len(d.fluxHistory) > d.historySize {
+		d.fluxHistory = d.fluxHistory[1:]
+	}
+
+	// Wait for enough history before trusting the threshold
+	if len(d.fluxHistory) < d.historySize/4 {
+		return BeatEvent{}, false
+	}
+
+	if flux <= threshold || now.Sub(d.lastBeat) < d.minInterval {
+		return BeatEvent{}, false
+	}
+
+	d.lastBeat = now
+	return BeatEvent{
+		Time:     now,
+		Strength: 1.0 - threshold/flux,
+	}, true
+}
+
+// Flux returns the spectral flux of the most recent frame
+func (d *BeatDetector) Flux() float64 {
+	return d.flux
+}
+
+// meanStdDev returns the mean and standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+
+	return mean, math.Sqrt(variance)
+}
@@ -0,0 +1,67 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// clickTrain returns mono audio with a short decaying noise burst every interval,
+// starting at first, over quiet background noise
+func clickTrain(duration, first, interval time.Duration, random *rand.Rand) []float64 {
+	samples := make([]float64, int(duration.Seconds()*defaultSampleRate))
+	for i := range samples {
+		samples[i] = 0.001 * (random.Float64()*2 - 1)
+	}
+	for click := first; click < duration; click += interval {
+		start := int(click.Seconds() * defaultSampleRate)
+		for i := 0; i < 441 && start+i < len(samples); i++ {
+			samples[start+i] += 0.8 * math.Exp(-float64(i)/100) * (random.Float64()*2 - 1)
+		}
+	}
+	return samples
+}
+
+func TestBeatDetectorCountsClicks(t *testing.T) {
+	tests := []struct {
+		bpm    float64
+		clicks int
+	}{
+		{90, 14},
+		{120, 18},
+		{150, 23},
+	}
+	for _, test := range tests {
+		// Clicks from 1 s on, once the adaptive threshold has history
+		interval := time.Duration(60 / test.bpm * float64(time.Second))
+		samples := clickTrain(10*time.Second, time.Second, interval, rand.New(rand.NewSource(1)))
+
+		analyzer := NewSpectrumAnalyzer(defaultSampleRate, 2048, 32)
+		detector := NewBeatDetector(64)
+		start := time.Unix(0, 0)
+		var beats []time.Duration
+		for pos := 0; pos+defaultFramesPerBuffer <= len(samples); pos += defaultFramesPerBuffer {
+			analyzer.Process(samples[pos : pos+defaultFramesPerBuffer])
+			// The buffer is analyzed once its last sample arrived
+			now := start.Add(time.Duration(float64(pos+defaultFramesPerBuffer) / defaultSampleRate * float64(time.Second)))
+			if event, ok := detector.Process(analyzer.Magnitudes(), now); ok {
+				beats = append(beats, event.Time.Sub(start))
+			}
+		}
+
+		if len(beats) != test.clicks {
+			t.Errorf("%.0f BPM: %d beats, want %d: %v", test.bpm, len(beats), test.clicks, beats)
+			continue
+		}
+		// Each beat is reported while its click is in the 2048-sample FFT window; the
+		// window's taper can delay a click at the end of a buffer to the next one
+		window := time.Second * 2048 / defaultSampleRate
+		for i, beat := range beats {
+			click := time.Second + time.Duration(i)*interval
+			if late := beat - click; late < 0 || late > window {
+				t.Errorf("%.0f BPM: beat %d at %v, want within %v after %v", test.bpm, i, beat, window, click)
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// Spectrum range used for pitch classes; lower bins are too coarse to resolve semitones
+	chromaLowHz  = 150.0
+	chromaHighHz = 5000.0
+
+	// Short-term chroma follows notes, the long-term profile follows the key
+	chromaShortTau = 0.25
+	chromaLongTau  = 8.0
+
+	// Dominant pitch classes weaker than this relative to the mean are treated as noise
+	minPitchSalience = 1.5
+)
+
+// NoteNames lists pitch class names starting at C
+var NoteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// Krumhansl-Kessler key profiles, starting at the tonic
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// Key is an estimated musical key
+type Key struct {
+	Tonic      int     `json:"tonic"`      // Pitch class of the tonic (0 = C), -1 when unknown
+	Minor      bool    `json:"minor"`      // Minor rather than major mode
+	Confidence float64 `json:"confidence"` // Correlation with the key profile (0.0 - 1.0)
+}
+
+// String returns the key name, e.g. "A minor"
+func (k Key) String() string {
+	if k.Tonic < 0 || k.Tonic > 11 {
+		return "unknown"
+	}
+	mode := "major"
+	if k.Minor {
+		mode = "minor"
+	}
+	return fmt.Sprintf("%s %s", NoteNames[k.Tonic], mode)
+}
+
+// ChromaAnalyzer folds spectrum energy into the 12 pitch classes and estimates the key
+type ChromaAnalyzer struct {
+	chroma  [12]float64 // Short-term pitch class energy
+	profile [12]float64 // Long-term pitch class energy for key finding
+	frame   [12]float64
+}
+
+// NewChromaAnalyzer creates a chroma analyzer
+func NewChromaAnalyzer() *ChromaAnalyzer {
+	return &ChromaAnalyzer{}
+}
+
+// Process adds the magnitude spectrum of a buffer lasting dt seconds
+func (c *ChromaAnalyzer) Process(magnitudes []float64, binHz, dt float64) {
+	c.frame = [12]float64{}
+	if binHz > 0 {
+		low := int(math.Ceil(chromaLowHz / binHz))
+		high := int(chromaHighHz / binHz)
+		if high >= len(magnitudes) {
+			high = len(magnitudes) - 1
+		}
+
+		for bin := low; bin <= high; bin++ {
+			// Semitones relative to A4, folded onto C = 0
+			midi := 69 + 12*math.Log2(float64(bin)*binHz/440)
+			pitchClass := (int(math.Round(midi))%12 + 12) % 12
+			c.frame[pitchClass] += magnitudes[bin] * magnitudes[bin]
+		}
+	}
+
+	shortK := 1 - math.Exp(-dt/chromaShortTau)
+	longK := 1 - math.Exp(-dt/chromaLongTau)
+	for i := range c.frame {
+		c.chroma[i] += (c.frame[i] - c.chroma[i]) * shortK
+		c.profile[i] += (c.frame[i] - c.profile[i]) * longK
+	}
+}
+
+// Decay lets the chroma fade during silence without adding new energy
+func (c *ChromaAnalyzer) Decay(dt float64) {
+	shortK := math.Exp(-dt / chromaShortTau)
+	for i := range c.chroma {
+		c.chroma[i] *= shortK
+	}
+}
+
+// Chroma returns the short-term pitch class profile, normalized so the strongest class is 1.0
+func (c *ChromaAnalyzer) Chroma() [12]float64 {
+	var normalized [12]float64
+	maxEnergy := 0.0
+	for _, e := range c.chroma {
+		maxEnergy = math.Max(maxEnergy, e)
+	}
+	if maxEnergy <= 0 {
+		return normalized
+	}
+	for i, e := range c.chroma {
+		normalized[i] = e / maxEnergy
+	}
+	return normalized
+}
+
+// PitchClass returns the dominant pitch class (0 = C), or -1 when no class stands out
+func (c *ChromaAnalyzer) PitchClass() int {
+	best, total := 0, 0.0
+	for i, e := range c.chroma {
+		total += e
+		if e > c.chroma[best] {
+			best = i
+		}
+	}
+	if total <= 0 || c.chroma[best] < total/12*minPitchSalience {
+		return -1
+	}
+	return best
+}
+
+// Key estimates the key by correlating the long-term profile with all 24 major/minor key profiles
+func (c *ChromaAnalyzer) Key() Key {
+	key := Key{Tonic: -1}
+
+	total := 0.0
+	for _, e := range c.profile {
+		total += e
+	}
+	if total <= 0 {
+		return key
+	}
+
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, minor := range []bool{false, true} {
+			profile := majorProfile
+			if minor {
+				profile = minorProfile
+			}
+
+			var rotated [12]float64
+			for i := range rotated {
+				rotated[(i+tonic)%12] = profile[i]
+			}
+
+			if r := correlation(c.profile[:], rotated[:]); r > key.Confidence {
+				key = Key{Tonic: tonic, Minor: minor, Confidence: r}
+			}
+		}
+	}
+	return key
+}
+
+// correlation returns the Pearson correlation of two equally long series
+func correlation(a, b []float64) float64 {
+	meanA, _ := meanStdDev(a)
+	meanB, _ := meanStdDev(b)
+
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
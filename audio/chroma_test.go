@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// feedChroma runs seconds of summed sines at the given frequencies through a spectrum and chroma analyzer
+func feedChroma(freqs []float64, seconds float64) *ChromaAnalyzer {
+	analyzer := NewSpectrumAnalyzer(defaultSampleRate, 2*defaultFramesPerBuffer, 32)
+	chroma := NewChromaAnalyzer()
+
+	samples := make([]float64, 2*defaultFramesPerBuffer)
+	dt := float64(defaultFramesPerBuffer) / defaultSampleRate
+	for n := 0; n < int(seconds*defaultSampleRate); n += defaultFramesPerBuffer {
+		for i := range samples {
+			samples[i] = 0
+			for _, freq := range freqs {
+				samples[i] += 0.2 * math.Sin(2*math.Pi*freq*float64(n+i)/defaultSampleRate)
+			}
+		}
+		analyzer.Process(samples)
+		chroma.Process(analyzer.Magnitudes(), analyzer.BinHz(), dt)
+	}
+	return chroma
+}
+
+func TestChromaKeyOfTriad(t *testing.T) {
+	tests := []struct {
+		name  string
+		freqs []float64
+		want  string
+	}{
+		{"C major", []float64{261.63, 329.63, 392.00}, "C major"},
+		{"C major, inverted with the fifth below", []float64{196.00, 261.63, 329.63}, "C major"},
+		{"G major", []float64{392.00, 493.88, 587.33}, "G major"},
+		{"A minor", []float64{220.00, 261.63, 329.63}, "A minor"},
+	}
+	for _, test := range tests {
+		if got := feedChroma(test.freqs, 10).Key(); got.String() != test.want {
+			t.Errorf("%s: key = %s (confidence %.2f), want %s", test.name, got, got.Confidence, test.want)
+		}
+	}
+}
+
+func TestChromaPitchClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		freqs []float64
+		want  int
+	}{
+		{"A4", []float64{440}, 9},
+		{"C5", []float64{523.25}, 0},
+		{"F#4", []float64{369.99}, 6},
+		{"E6", []float64{1318.51}, 4},
+	}
+	for _, test := range tests {
+		if got := feedChroma(test.freqs, 1).PitchClass(); got != test.want {
+			t.Errorf("%s: pitch class = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestChromaKeyUnknownInSilence(t *testing.T) {
+	if got := feedChroma(nil, 1).Key(); got.String() != "unknown" {
+		t.Errorf("key of silence = %s, want unknown", got)
+	}
+}
@@ -0,0 +1,44 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// FindDevice resolves a device spec against all PortAudio devices. A number is the index
+// shown by the devices command; anything else matches the device name, exact names
+// first, then case-insensitive substrings. Only devices with input channels match
+func FindDevice(devices []*portaudio.DeviceInfo, spec string) (*portaudio.DeviceInfo, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty device name")
+	}
+
+	if index, err := strconv.Atoi(spec); err == nil {
+		if index < 0 || index >= len(devices) {
+			return nil, fmt.Errorf("device index %d out of range (0-%d)", index, len(devices)-1)
+		}
+		if devices[index].MaxInputChannels < 1 {
+			return nil, fmt.Errorf("device %d (%s) has no input channels", index, devices[index].Name)
+		}
+		return devices[index], nil
+	}
+
+	for _, device := range devices {
+		if device.MaxInputChannels > 0 && device.Name == spec {
+			return device, nil
+		}
+	}
+
+	needle := strings.ToLower(spec)
+	for _, device := range devices {
+		if device.MaxInputChannels > 0 && strings.Contains(strings.ToLower(device.Name), needle) {
+			return device, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no input device matching %q (run the devices command to list them)", spec)
+}
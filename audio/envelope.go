@@ -0,0 +1,95 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// Default level envelope, roughly matching the old per-pattern history averages
+	defaultEnvelopeAttack  = 0.05
+	defaultEnvelopeRelease = 0.4
+
+	// Default peak-hold: hold for half a second, then fall over about a second
+	defaultPeakHoldTime = 0.5
+	defaultPeakFallRate = 1.0
+)
+
+// Envelope smooths a level with separate attack and release times
+type Envelope struct {
+	attack  float64 // Seconds to follow a rising level
+	release float64 // Seconds to follow a falling level
+	value   float64
+}
+
+// NewEnvelope creates an envelope follower with attack/release times in seconds
+func NewEnvelope(attack, release float64) *Envelope {
+	e := &Envelope{}
+	e.SetTimes(attack, release)
+	return e
+}
+
+// SetTimes changes the attack and release times; non-positive times follow the level instantly
+func (e *Envelope) SetTimes(attack, release float64) {
+	e.attack = math.Max(attack, 0)
+	e.release = math.Max(release, 0)
+}
+
+// Process feeds a level lasting dt seconds and returns the smoothed value
+func (e *Envelope) Process(level, dt float64) float64 {
+	tau := e.release
+	if level > e.value {
+		tau = e.attack
+	}
+
+	if tau <= 0 {
+		e.value = level
+	} else {
+		e.value += (level - e.value) * (1 - math.Exp(-dt/tau))
+	}
+	return e.value
+}
+
+// Value returns the current smoothed level
+func (e *Envelope) Value() float64 {
+	return e.value
+}
+
+// PeakHold keeps the highest recent level for a hold time, then lets it fall at a constant rate
+type PeakHold struct {
+	hold     float64 // Seconds to hold a peak
+	fallRate float64 // Level units per second once the hold expires
+	value    float64
+	age      float64 // Seconds since the held peak was set
+}
+
+// NewPeakHold creates a peak-hold with a hold time in seconds and a fall rate in level units per second
+func NewPeakHold(hold, fallRate float64) *PeakHold {
+	h := &PeakHold{}
+	h.SetTimes(hold, fallRate)
+	return h
+}
+
+// SetTimes changes the hold time and fall rate
+func (h *PeakHold) SetTimes(hold, fallRate float64) {
+	h.hold = math.Max(hold, 0)
+	h.fallRate = math.Max(fallRate, 0)
+}
+
+// Process feeds a level lasting dt seconds and returns the held peak
+func (h *PeakHold) Process(level, dt float64) float64 {
+	h.age += dt
+
+	if h.age > h.hold {
+		h.value = math.Max(h.value-h.fallRate*dt, 0)
+	}
+	if level >= h.value {
+		h.value = level
+		h.age = 0
+	}
+	return h.value
+}
+
+// Value returns the current held peak
+func (h *PeakHold) Value() float64 {
+	return h.value
+}
@@ -0,0 +1,53 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPeakHoldDecay(t *testing.T) {
+	tests := []struct {
+		name    string
+		silence float64 // Seconds of silence after a 0.8 peak
+		want    float64
+	}{
+		{"holding", 0.3, 0.8},
+		{"end of hold", 0.5, 0.8},
+		{"falling", 1.0, 0.3},
+		{"floored at zero", 2.0, 0},
+	}
+	for _, test := range tests {
+		hold := NewPeakHold(defaultPeakHoldTime, defaultPeakFallRate)
+
+		const dt = 0.01
+		hold.Process(0.8, dt)
+		for elapsed := 0.0; elapsed < test.silence-dt/2; elapsed += dt {
+			hold.Process(0, dt)
+		}
+		// Allow one buffer of slack for where the hold time falls between buffers
+		if got := hold.Value(); math.Abs(got-test.want) > defaultPeakFallRate*dt+1e-9 {
+			t.Errorf("%s: peak after %vs = %.3f, want %.3f", test.name, test.silence, got, test.want)
+		}
+	}
+}
+
+func TestPeakHoldNewPeak(t *testing.T) {
+	tests := []struct {
+		name  string
+		level float64
+		want  float64
+	}{
+		{"louder peak replaces the held one", 0.9, 0.9},
+		{"quieter level does not restart the hold", 0.5, 0.4},
+	}
+	for _, test := range tests {
+		hold := NewPeakHold(defaultPeakHoldTime, defaultPeakFallRate)
+		hold.Process(0.8, 0.01)
+		hold.Process(test.level, 0.2)
+
+		// Only a new peak restarts the hold; otherwise the 0.8 peak is past its hold time and falls
+		if got := hold.Process(0, 0.4); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: peak = %.3f, want %.3f", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Longest feature log line accepted; large spectra make lines a few KB
+const maxFeatureLineSize = 1 << 20
+
+// FeatureRecord is one analyzed buffer in a feature log (one JSON object per line)
+type FeatureRecord struct {
+	Time         float64            `json:"t"` // Seconds since the first recorded buffer
+	Peak         float64            `json:"peak"`
+	RMS          float64            `json:"rms"`
+	Envelope     float64            `json:"envelope"`
+	PeakHold     float64            `json:"peak_hold"`
+	Channels     []float64          `json:"channels,omitempty"`
+	Stereo       StereoImage        `json:"stereo"`
+	Bands        map[string]float64 `json:"bands"`
+	Chroma       [12]float64        `json:"chroma"`
+	PitchClass   int                `json:"pitch_class"`
+	Key          Key                `json:"key"`
+	Spectrum     []float64          `json:"spectrum"`
+	Beat         bool               `json:"beat,omitempty"`
+	BeatStrength float64            `json:"beat_strength,omitempty"`
+	Tempo        float64            `json:"tempo"`
+	BeatPhase    float64            `json:"beat_phase"`
+	Silent       bool               `json:"silent,omitempty"`
+}
+
+// NewFeatureRecord captures the features of a frame relative to the start of a recording.
+// The waveform is left out to keep logs small
+func NewFeatureRecord(frame AudioFrame, start time.Time) FeatureRecord {
+	return FeatureRecord{
+		Time:         frame.Timestamp.Sub(start).Seconds(),
+		Peak:         frame.Peak,
+		RMS:          frame.RMS,
+		Envelope:     frame.Envelope,
+		PeakHold:     frame.PeakHold,
+		Channels:     frame.Channels,
+		Stereo:       frame.Stereo,
+		Bands:        frame.Bands,
+		Chroma:       frame.Chroma,
+		PitchClass:   frame.PitchClass,
+		Key:          frame.Key,
+		Spectrum:     frame.Spectrum,
+		Beat:         frame.Beat,
+		BeatStrength: frame.BeatStrength,
+		Tempo:        frame.Tempo,
+		BeatPhase:    frame.BeatPhase,
+		Silent:       frame.Silent,
+	}
+}
+
+// Frame turns the record back into an audio frame for a replay that started at start
+func (r FeatureRecord) Frame(start time.Time) AudioFrame {
+	return AudioFrame{
+		Peak:         r.Peak,
+		RMS:          r.RMS,
+		Envelope:     r.Envelope,
+		PeakHold:     r.PeakHold,
+		Channels:     r.Channels,
+		Stereo:       r.Stereo,
+		Bands:        r.Bands,
+		Chroma:       r.Chroma,
+		PitchClass:   r.PitchClass,
+		Key:          r.Key,
+		Spectrum:     r.Spectrum,
+		Beat:         r.Beat,
+		BeatStrength: r.BeatStrength,
+		Tempo:        r.Tempo,
+		BeatPhase:    r.BeatPhase,
+		Silent:       r.Silent,
+		Timestamp:    start.Add(time.Duration(r.Time * float64(time.Second))),
+	}
+}
+
+// LoadFeatureLog reads a feature log written by the recorder
+func LoadFeatureLog(path string) ([]FeatureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var records []FeatureRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxFeatureLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record FeatureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %v", path, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s contains no feature records", path)
+	}
+	return records, nil
+}
+
+// FeatureReplay plays back a feature log on its original timeline, like a player
+// whose analysis was recorded earlier
+type FeatureReplay struct {
+	records []FeatureRecord
+	start   time.Time
+	next    int // First record not yet returned
+}
+
+// NewFeatureReplay starts replaying records at start
+func NewFeatureReplay(records []FeatureRecord, start time.Time) *FeatureReplay {
+	return &FeatureReplay{records: records, start: start}
+}
+
+// Frame returns the latest recorded frame at the given time. Like Player.GetAudioFrame,
+// a beat in any record since the previous call is reported exactly once
+func (r *FeatureReplay) Frame(now time.Time) AudioFrame {
+	elapsed := now.Sub(r.start).Seconds()
+
+	beat, strength := false, 0.0
+	for r.next < len(r.records) && r.records[r.next].Time <= elapsed {
+		if record := r.records[r.next]; record.Beat {
+			beat = true
+			strength = math.Max(strength, record.BeatStrength)
+		}
+		r.next++
+	}
+
+	current := r.next - 1
+	if current < 0 {
+		current = 0
+	}
+	frame := r.records[current].Frame(r.start)
+	frame.Beat, frame.BeatStrength = beat, strength
+	return frame
+}
+
+// Done returns whether the last record has been played at the given time
+func (r *FeatureReplay) Done(now time.Time) bool {
+	return now.Sub(r.start) >= r.Duration()
+}
+
+// Duration returns the length of the recorded timeline
+func (r *FeatureReplay) Duration() time.Duration {
+	return time.Duration(r.records[len(r.records)-1].Time * float64(time.Second))
+}
+
+// Restart plays the log again from start
+func (r *FeatureReplay) Restart(start time.Time) {
+	r.start = start
+	r.next = 0
+}
@@ -0,0 +1,554 @@
+package audio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrCorrupt is returned for audio data that violates its format, including files cut off mid-frame
+var ErrCorrupt = errors.New("corrupt audio data")
+
+// Largest Rice quotient accepted; valid residuals of up to 32-bit samples stay far below it
+const maxRiceQuotient = 1 << 32
+
+// flacDecoder streams samples from a native FLAC file.
+// CRCs and the MD5 signature are not verified.
+type flacDecoder struct {
+	file   *os.File
+	bits   *bitReader
+	rate   float64
+	nChans int
+	bps    int
+
+	// Decoded samples of the current frame and how many were handed out
+	block   [][]int64
+	decoded [][]float32
+	offset  int
+	size    int
+}
+
+// Sample rates for FLAC frame header codes 1-11
+var flacSampleRates = []int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+// Bits per sample for FLAC frame header codes, 0 means "from STREAMINFO"
+var flacSampleSizes = []int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// newFLACDecoder reads the metadata blocks up to the first audio frame
+func newFLACDecoder(file *os.File, reader *bufio.Reader) (*flacDecoder, error) {
+	// Skip an ID3v2 tag some taggers prepend
+	if header, err := reader.Peek(10); err == nil && string(header[:3]) == "ID3" {
+		size := int(header[6])<<21 | int(header[7])<<14 | int(header[8])<<7 | int(header[9])
+		if _, err := reader.Discard(10 + size); err != nil {
+			return nil, err
+		}
+	}
+
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(reader, marker); err != nil || string(marker) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC file")
+	}
+
+	d := &flacDecoder{file: file, bits: &bitReader{r: reader}}
+	haveInfo := false
+
+	for {
+		last, err := d.bits.readBits(1)
+		if err != nil {
+			return nil, err
+		}
+		blockType, _ := d.bits.readBits(7)
+		length, err := d.bits.readBits(24)
+		if err != nil {
+			return nil, err
+		}
+
+		if blockType == 0 {
+			if length < 34 {
+				return nil, fmt.Errorf("%w: short STREAMINFO block", ErrCorrupt)
+			}
+			// STREAMINFO
+			d.bits.readBits(16) // Min block size
+			d.bits.readBits(16) // Max block size
+			d.bits.readBits(24) // Min frame size
+			d.bits.readBits(24) // Max frame size
+			rate, _ := d.bits.readBits(20)
+			channels, _ := d.bits.readBits(3)
+			bps, _ := d.bits.readBits(5)
+			d.bits.readBits(36) // Total samples
+			if _, err := reader.Discard(16 + int(length) - 34); err != nil {
+				return nil, err
+			}
+
+			d.rate = float64(rate)
+			d.nChans = int(channels) + 1
+			d.bps = int(bps) + 1
+			haveInfo = true
+		} else if _, err := reader.Discard(int(length)); err != nil {
+			return nil, err
+		}
+
+		if last == 1 {
+			break
+		}
+	}
+
+	if !haveInfo || d.rate == 0 {
+		return nil, fmt.Errorf("missing STREAMINFO block")
+	}
+
+	d.block = make([][]int64, d.nChans)
+	d.decoded = make([][]float32, d.nChans)
+	return d, nil
+}
+
+// read copies decoded frames into buffer, decoding new FLAC frames as needed.
+// Returns io.EOF at the end of the stream and an ErrCorrupt error for broken frames
+func (d *flacDecoder) read(buffer [][]float32) (int, error) {
+	frames := len(buffer[0])
+	filled := 0
+
+	for filled < frames {
+		if d.offset >= d.size {
+			if err := d.decodeFrame(); err != nil {
+				return filled, err
+			}
+		}
+
+		n := frames - filled
+		if available := d.size - d.offset; n > available {
+			n = available
+		}
+		for ch := range buffer {
+			src := ch
+			if src >= d.nChans {
+				src = d.nChans - 1
+			}
+			copy(buffer[ch][filled:filled+n], d.decoded[src][d.offset:d.offset+n])
+		}
+		filled += n
+		d.offset += n
+	}
+
+	return filled, nil
+}
+
+func (d *flacDecoder) sampleRate() float64 { return d.rate }
+func (d *flacDecoder) channels() int       { return d.nChans }
+func (d *flacDecoder) close() error        { return d.file.Close() }
+
+// decodeFrame decodes the next audio frame into d.decoded; io.EOF means the stream ended
+// between frames (trailing tags are skipped like any data before a sync code)
+func (d *flacDecoder) decodeFrame() error {
+	b := d.bits
+
+	// Find the frame sync code (0xFFF8 or 0xFFF9)
+	b.align()
+	prev := uint64(0)
+	for {
+		c, err := b.readBits(8)
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read FLAC frame: %v", err)
+		}
+		if prev == 0xFF && c&0xFE == 0xF8 {
+			break
+		}
+		prev = c
+	}
+
+	if err := d.decodeFrameBody(); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated frame", ErrCorrupt)
+		}
+		return err
+	}
+	return nil
+}
+
+// decodeFrameBody decodes the frame following a sync code
+func (d *flacDecoder) decodeFrameBody() error {
+	b := d.bits
+
+	blockSizeCode, _ := b.readBits(4)
+	rateCode, _ := b.readBits(4)
+	channelCode, _ := b.readBits(4)
+	sizeCode, _ := b.readBits(3)
+	b.readBits(1)
+
+	// Frame or sample number in UTF-8 style coding; only its length matters
+	first, err := b.readBits(8)
+	if err != nil {
+		return err
+	}
+	leadingOnes := 0
+	for mask := uint64(0x80); first&mask != 0 && mask > 0; mask >>= 1 {
+		leadingOnes++
+	}
+	if leadingOnes == 1 || leadingOnes > 7 {
+		return fmt.Errorf("%w: invalid frame number", ErrCorrupt)
+	}
+	for i := 1; i < leadingOnes; i++ {
+		b.readBits(8)
+	}
+
+	var blockSize int
+	switch {
+	case blockSizeCode == 1:
+		blockSize = 192
+	case blockSizeCode >= 2 && blockSizeCode <= 5:
+		blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		v, _ := b.readBits(8)
+		blockSize = int(v) + 1
+	case blockSizeCode == 7:
+		v, _ := b.readBits(16)
+		blockSize = int(v) + 1
+	case blockSizeCode >= 8:
+		blockSize = 256 << (blockSizeCode - 8)
+	default:
+		return fmt.Errorf("%w: reserved block size", ErrCorrupt)
+	}
+
+	switch rateCode {
+	case 12:
+		b.readBits(8)
+	case 13, 14:
+		b.readBits(16)
+	case 15:
+		return fmt.Errorf("%w: invalid sample rate", ErrCorrupt)
+	}
+	if rateCode >= 1 && int(rateCode) < len(flacSampleRates) && float64(flacSampleRates[rateCode]) != d.rate {
+		return fmt.Errorf("sample rate changes mid-stream are not supported")
+	}
+
+	if sizeCode == 3 {
+		return fmt.Errorf("%w: reserved sample size", ErrCorrupt)
+	}
+	bps := flacSampleSizes[sizeCode]
+	if bps == 0 {
+		bps = d.bps
+	}
+
+	b.readBits(8) // Header CRC-8
+
+	channels := int(channelCode) + 1
+	if channelCode >= 8 {
+		if channelCode > 10 {
+			return fmt.Errorf("%w: reserved channel assignment", ErrCorrupt)
+		}
+		channels = 2
+	}
+	if channels != d.nChans {
+		return fmt.Errorf("channel count changes mid-stream are not supported")
+	}
+
+	for ch := 0; ch < channels; ch++ {
+		if cap(d.block[ch]) < blockSize {
+			d.block[ch] = make([]int64, blockSize)
+			d.decoded[ch] = make([]float32, blockSize)
+		}
+		d.block[ch] = d.block[ch][:blockSize]
+
+		// Side channels carry one extra bit
+		subframeBPS := bps
+		if (channelCode == 8 && ch == 1) || (channelCode == 9 && ch == 0) || (channelCode == 10 && ch == 1) {
+			subframeBPS++
+		}
+		if err := d.decodeSubframe(d.block[ch], subframeBPS); err != nil {
+			return err
+		}
+	}
+
+	b.align()
+	b.readBits(16) // Frame CRC-16
+
+	// Undo inter-channel decorrelation
+	switch channelCode {
+	case 8: // Left/side
+		for i := 0; i < blockSize; i++ {
+			d.block[1][i] = d.block[0][i] - d.block[1][i]
+		}
+	case 9: // Side/right
+		for i := 0; i < blockSize; i++ {
+			d.block[0][i] += d.block[1][i]
+		}
+	case 10: // Mid/side
+		for i := 0; i < blockSize; i++ {
+			mid := d.block[0][i]<<1 | d.block[1][i]&1
+			side := d.block[1][i]
+			d.block[0][i] = (mid + side) >> 1
+			d.block[1][i] = (mid - side) >> 1
+		}
+	}
+
+	scale := float32(int64(1) << (bps - 1))
+	for ch := 0; ch < channels; ch++ {
+		d.decoded[ch] = d.decoded[ch][:blockSize]
+		for i, v := range d.block[ch] {
+			d.decoded[ch][i] = float32(v) / scale
+		}
+	}
+
+	d.offset = 0
+	d.size = blockSize
+	return nil
+}
+
+// decodeSubframe decodes one channel of a frame into samples
+func (d *flacDecoder) decodeSubframe(samples []int64, bps int) error {
+	b := d.bits
+
+	padding, _ := b.readBits(1)
+	subframeType, err := b.readBits(6)
+	if err != nil {
+		return err
+	}
+	if padding != 0 {
+		return fmt.Errorf("%w: invalid subframe header", ErrCorrupt)
+	}
+
+	// Wasted bits are shifted out of every sample
+	wasted := 0
+	if flag, _ := b.readBits(1); flag == 1 {
+		k, err := b.readUnary()
+		if err != nil {
+			return err
+		}
+		if k+1 >= uint64(bps) {
+			return fmt.Errorf("%w: %d wasted bits in %d-bit samples", ErrCorrupt, k+1, bps)
+		}
+		wasted = int(k) + 1
+		bps -= wasted
+	}
+
+	// Warm-up samples of a predictor must fit in the block
+	order := 0
+	switch {
+	case subframeType >= 8 && subframeType <= 12:
+		order = int(subframeType - 8)
+	case subframeType >= 32:
+		order = int(subframeType - 31)
+	}
+	if order > len(samples) {
+		return fmt.Errorf("%w: predictor order %d exceeds block size %d", ErrCorrupt, order, len(samples))
+	}
+
+	switch {
+	case subframeType == 0: // Constant
+		v, err := b.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = v
+		}
+
+	case subframeType == 1: // Verbatim
+		for i := range samples {
+			v, err := b.readSigned(uint(bps))
+			if err != nil {
+				return err
+			}
+			samples[i] = v
+		}
+
+	case subframeType >= 8 && subframeType <= 12: // Fixed predictor
+		for i := 0; i < order; i++ {
+			if samples[i], err = b.readSigned(uint(bps)); err != nil {
+				return err
+			}
+		}
+		if err := d.decodeResidual(samples, order); err != nil {
+			return err
+		}
+		fixedPredict(samples, order)
+
+	case subframeType >= 32: // LPC
+		for i := 0; i < order; i++ {
+			if samples[i], err = b.readSigned(uint(bps)); err != nil {
+				return err
+			}
+		}
+
+		precision, _ := b.readBits(4)
+		if precision == 15 {
+			return fmt.Errorf("%w: invalid LPC precision", ErrCorrupt)
+		}
+		shift, _ := b.readSigned(5)
+		if shift < 0 {
+			return fmt.Errorf("%w: negative LPC shift", ErrCorrupt)
+		}
+		coefficients := make([]int64, order)
+		for i := range coefficients {
+			if coefficients[i], err = b.readSigned(uint(precision + 1)); err != nil {
+				return err
+			}
+		}
+
+		if err := d.decodeResidual(samples, order); err != nil {
+			return err
+		}
+		for i := order; i < len(samples); i++ {
+			sum := int64(0)
+			for j, c := range coefficients {
+				sum += c * samples[i-1-j]
+			}
+			samples[i] += sum >> uint(shift)
+		}
+
+	default:
+		return fmt.Errorf("%w: reserved subframe type %d", ErrCorrupt, subframeType)
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= uint(wasted)
+		}
+	}
+	return nil
+}
+
+// decodeResidual reads Rice-coded residuals into samples[order:]
+func (d *flacDecoder) decodeResidual(samples []int64, order int) error {
+	b := d.bits
+
+	method, _ := b.readBits(2)
+	if method > 1 {
+		return fmt.Errorf("%w: reserved residual coding method", ErrCorrupt)
+	}
+	paramBits := uint(4 + method)
+	escape := uint64(1)<<paramBits - 1
+
+	partitionOrder, err := b.readBits(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	partitionSize := len(samples) >> partitionOrder
+
+	// Partitions split the block evenly, and the first one must hold the warm-up samples
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return fmt.Errorf("%w: %d residual partitions in a block of %d with order %d", ErrCorrupt, partitions, len(samples), order)
+	}
+
+	pos := order
+	for p := 0; p < partitions; p++ {
+		count := partitionSize
+		if p == 0 {
+			count -= order
+		}
+
+		param, err := b.readBits(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			// Unencoded partition with a fixed sample size
+			size, _ := b.readBits(5)
+			for i := 0; i < count; i++ {
+				if samples[pos], err = b.readSigned(uint(size)); err != nil {
+					return err
+				}
+				pos++
+			}
+			continue
+		}
+
+		for i := 0; i < count; i++ {
+			quotient, err := b.readUnary()
+			if err != nil {
+				return err
+			}
+			if quotient > maxRiceQuotient {
+				return fmt.Errorf("%w: residual out of range", ErrCorrupt)
+			}
+			low, err := b.readBits(uint(param))
+			if err != nil {
+				return err
+			}
+			u := quotient<<param | low
+			samples[pos] = int64(u>>1) ^ -int64(u&1)
+			pos++
+		}
+	}
+
+	return nil
+}
+
+// fixedPredict restores samples from residuals using FLAC's fixed polynomial predictors
+func fixedPredict(s []int64, order int) {
+	for i := order; i < len(s); i++ {
+		switch order {
+		case 1:
+			s[i] += s[i-1]
+		case 2:
+			s[i] += 2*s[i-1] - s[i-2]
+		case 3:
+			s[i] += 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			s[i] += 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+	}
+}
+
+// bitReader reads big-endian bit fields from a byte stream
+type bitReader struct {
+	r     *bufio.Reader
+	cache uint64
+	n     uint // Unread bits held in the low end of cache
+}
+
+// readBits reads an unsigned value of up to 56 bits
+func (b *bitReader) readBits(n uint) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	for b.n < n {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		b.cache = b.cache<<8 | uint64(c)
+		b.n += 8
+	}
+	b.n -= n
+	return (b.cache >> b.n) & (1<<n - 1), nil
+}
+
+// readSigned reads a two's complement value of n bits
+func (b *bitReader) readSigned(n uint) (int64, error) {
+	v, err := b.readBits(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	if v&(1<<(n-1)) != 0 {
+		return int64(v) - int64(1)<<n, nil
+	}
+	return int64(v), nil
+}
+
+// readUnary counts zero bits up to the next one bit
+func (b *bitReader) readUnary() (uint64, error) {
+	count := uint64(0)
+	for {
+		bit, err := b.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if bit == 1 {
+			return count, nil
+		}
+		count++
+	}
+}
+
+// align drops the remaining bits of a partially read byte
+func (b *bitReader) align() {
+	b.n -= b.n % 8
+}
@@ -0,0 +1,196 @@
+package audio
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fixtures are listed in testdata/flac/README.md; each .s32 file holds the interleaved
+// samples of the FLAC file with the same name
+
+// decodeAll reads a decoder to the end, interleaving its frames
+func decodeAll(decoder audioDecoder) ([]float32, error) {
+	buffer := make([][]float32, decoder.channels())
+	for ch := range buffer {
+		buffer[ch] = make([]float32, 300)
+	}
+
+	var out []float32
+	for {
+		n, err := decoder.read(buffer)
+		for i := 0; i < n; i++ {
+			for ch := range buffer {
+				out = append(out, buffer[ch][i])
+			}
+		}
+		if err != nil {
+			return out, err
+		}
+	}
+}
+
+func TestFLACDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		channels int
+		bps      int
+	}{
+		{"fixed", 44100, 2, 16},
+		{"lpc", 48000, 1, 24},
+		{"left_side", 44100, 2, 16},
+		{"right_side", 44100, 2, 16},
+		{"mid_side", 44100, 2, 16},
+		{"rice_escape", 44100, 2, 16},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", "flac", test.name+".s32"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			scale := float32(int64(1) << (test.bps - 1))
+			want := make([]float32, len(raw)/4)
+			for i := range want {
+				want[i] = float32(int32(binary.LittleEndian.Uint32(raw[i*4:]))) / scale
+			}
+
+			decoder, err := openDecoder(filepath.Join("testdata", "flac", test.name+".flac"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoder.close()
+			if decoder.sampleRate() != test.rate || decoder.channels() != test.channels {
+				t.Errorf("%v Hz, %d channels, want %v Hz, %d channels", decoder.sampleRate(), decoder.channels(), test.rate, test.channels)
+			}
+
+			got, err := decodeAll(decoder)
+			if err != io.EOF {
+				t.Fatalf("decoding ended with %v, want io.EOF", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("decoded %d samples, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("sample %d (frame %d, channel %d) = %v, want %v", i, i/test.channels, i%test.channels, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestFLACDecodeSignature checks every fixture against the MD5 signature in its STREAMINFO
+// block, so files from other encoders can be added without reference samples
+func TestFLACDecodeSignature(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "flac", "*.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasPrefix(name, "corrupt_") {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// STREAMINFO is always the first metadata block: bits per sample straddle
+			// bytes 20-21 and the signature follows the total sample count
+			if len(data) < 42 {
+				t.Fatal("file too short for STREAMINFO")
+			}
+			bps := int(data[20]&1)<<4 | int(data[21]>>4) + 1
+			signature := data[26:42]
+			if bytes.Equal(signature, make([]byte, 16)) {
+				t.Skip("encoder left the signature unset")
+			}
+
+			decoder, err := openDecoder(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer decoder.close()
+			samples, err := decodeAll(decoder)
+			if err != io.EOF {
+				t.Fatalf("decoding ended with %v, want io.EOF", err)
+			}
+
+			scale := float64(int64(1) << (bps - 1))
+			width := (bps + 7) / 8
+			raw := make([]byte, 0, len(samples)*width)
+			for _, sample := range samples {
+				v := int64(math.Round(float64(sample) * scale))
+				for b := 0; b < width; b++ {
+					raw = append(raw, byte(v>>(8*b)))
+				}
+			}
+			if sum := md5.Sum(raw); !bytes.Equal(sum[:], signature) {
+				t.Errorf("MD5 of the decoded samples is %x, STREAMINFO says %x", sum, signature)
+			}
+		})
+	}
+}
+
+func TestFLACDecodeCorrupt(t *testing.T) {
+	fixed, err := os.ReadFile(filepath.Join("testdata", "flac", "fixed.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(t.TempDir(), "truncated.flac")
+	if err := os.WriteFile(truncated, fixed[:len(fixed)-500], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"predictor order above block size", filepath.Join("testdata", "flac", "corrupt_order.flac")},
+		{"partitions not splitting the block", filepath.Join("testdata", "flac", "corrupt_partitions.flac")},
+		{"truncated mid-frame", truncated},
+	}
+	for _, test := range tests {
+		decoder, err := openDecoder(test.path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if _, err := decodeAll(decoder); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: decoding ended with %v, want ErrCorrupt", test.name, err)
+		}
+		decoder.close()
+	}
+}
+
+func FuzzFLACDecode(f *testing.F) {
+	for _, name := range []string{"fixed", "lpc", "mid_side", "rice_escape"} {
+		data, err := os.ReadFile(filepath.Join("testdata", "flac", name+".flac"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data[:min(len(data), 2048)])
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "fuzz.flac")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		decoder, err := openDecoder(path)
+		if err != nil {
+			return
+		}
+		defer decoder.close()
+		decodeAll(decoder)
+	})
+}
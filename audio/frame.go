@@ -0,0 +1,32 @@
+package audio
+
+import (
+	"time"
+)
+
+// AudioFrame bundles every audio feature a visualizer needs for one frame
+type AudioFrame struct {
+	Peak         float64            // Absolute peak of the latest buffer after sensitivity (0.0 - 1.0)
+	RMS          float64            // RMS level of the latest buffer after sensitivity (0.0 - 1.0)
+	Envelope     float64            // Peak smoothed with attack/release (0.0 - 1.0)
+	PeakHold     float64            // Recent maximum peak, falling slowly after the hold time (0.0 - 1.0)
+	Channels     []float64          // Peak of each channel after gain (0.0 - 1.0)
+	Stereo       StereoImage        // Balance, correlation and mid/side levels
+	Bands        map[string]float64 // Smoothed energy per named frequency band
+	Chroma       [12]float64        // Pitch class energy, C first (strongest = 1.0)
+	PitchClass   int                // Dominant pitch class (0 = C), -1 when none stands out
+	Key          Key                // Estimated musical key
+	Spectrum     []float64          // Grouped magnitude spectrum (0.0 - 1.0 per bin)
+	Waveform     []float64          // Most recent mono samples
+	Beat         bool               // A beat was detected since the previous frame
+	BeatStrength float64            // Strength of that beat (0.0 - 1.0)
+	Tempo        float64            // Estimated tempo in BPM, 0 when unknown
+	BeatPhase    float64            // Position within the current beat (0.0 - 1.0)
+	Silent       bool               // Nothing above the noise gate for the idle timeout
+	Timestamp    time.Time
+}
+
+// Band returns the energy of a named band, or 0 if it is not present
+func (f AudioFrame) Band(name string) float64 {
+	return f.Bands[name]
+}
@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"time"
+)
+
+const (
+	// Default gate: open above -60 dBFS RMS, close again below -66 dBFS
+	defaultGateThreshold  = -60.0
+	defaultGateHysteresis = 6.0
+
+	// Silence lasting this long switches the app to idle mode
+	defaultIdleAfter = 10 * time.Second
+)
+
+// NoiseGate mutes levels below a threshold, with hysteresis so it does not chatter
+type NoiseGate struct {
+	openDB  float64 // Level (dBFS) at which a closed gate opens
+	closeDB float64 // Level (dBFS) below which an open gate closes
+	open    bool
+	since   time.Time // When the gate last opened or closed
+}
+
+// NewNoiseGate creates a closed gate opening at thresholdDB and closing hysteresisDB below it
+func NewNoiseGate(thresholdDB, hysteresisDB float64) *NoiseGate {
+	g := &NoiseGate{since: time.Now()}
+	g.SetThreshold(thresholdDB, hysteresisDB)
+	return g
+}
+
+// SetThreshold changes the opening level and hysteresis; a threshold at or below SilenceDB disables the gate
+func (g *NoiseGate) SetThreshold(thresholdDB, hysteresisDB float64) {
+	if hysteresisDB < 0 {
+		hysteresisDB = 0
+	}
+	g.openDB = thresholdDB
+	g.closeDB = thresholdDB - hysteresisDB
+}
+
+// Threshold returns the level at which the gate opens
+func (g *NoiseGate) Threshold() float64 {
+	return g.openDB
+}
+
+// Process feeds a level in dBFS measured at the given time and returns whether the gate is open
+func (g *NoiseGate) Process(levelDB float64, now time.Time) bool {
+	open := g.open
+	switch {
+	case g.openDB <= SilenceDB:
+		open = true
+	case g.open && levelDB < g.closeDB:
+		open = false
+	case !g.open && levelDB >= g.openDB:
+		open = true
+	}
+
+	if open != g.open {
+		g.open = open
+		g.since = now
+	}
+	return g.open
+}
+
+// IsOpen returns whether audio is currently passing the gate
+func (g *NoiseGate) IsOpen() bool {
+	return g.open
+}
+
+// Since returns when the gate last opened or closed
+func (g *NoiseGate) Since() time.Time {
+	return g.since
+}
@@ -0,0 +1,57 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNoiseGateHysteresis(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []float64 // dBFS, one per buffer
+		want   []bool    // Gate state after each buffer
+	}{
+		{"stays closed below the threshold", []float64{-80, -61, -60.5}, []bool{false, false, false}},
+		{"opens at the threshold", []float64{-80, -60}, []bool{false, true}},
+		{"holds open inside the hysteresis band", []float64{-40, -61, -65.9, -63}, []bool{true, true, true, true}},
+		{"closes below the band", []float64{-40, -66.1}, []bool{true, false}},
+		{"reopens only at the threshold", []float64{-40, -70, -63, -60}, []bool{true, false, false, true}},
+		{"silence", []float64{SilenceDB}, []bool{false}},
+	}
+	for _, test := range tests {
+		gate := NewNoiseGate(defaultGateThreshold, defaultGateHysteresis)
+		for i, level := range test.levels {
+			if got := gate.Process(level, time.Now()); got != test.want[i] {
+				t.Errorf("%s: buffer %d at %v dBFS: open = %v, want %v", test.name, i, level, got, test.want[i])
+			}
+		}
+	}
+}
+
+func TestNoiseGateDisabled(t *testing.T) {
+	gate := NewNoiseGate(SilenceDB, defaultGateHysteresis)
+	if !gate.Process(SilenceDB, time.Now()) {
+		t.Error("gate with a threshold at SilenceDB stayed closed")
+	}
+}
+
+func TestNoiseGateSince(t *testing.T) {
+	start := time.Now()
+	gate := NewNoiseGate(defaultGateThreshold, defaultGateHysteresis)
+
+	tests := []struct {
+		level float64
+		at    time.Duration
+		since time.Duration // When the gate should report its last change
+	}{
+		{-40, time.Second, time.Second},
+		{-63, 2 * time.Second, time.Second},
+		{-70, 3 * time.Second, 3 * time.Second},
+	}
+	for _, test := range tests {
+		gate.Process(test.level, start.Add(test.at))
+		if got := gate.Since().Sub(start); got != test.since {
+			t.Errorf("%v dBFS at %v: since = %v, want %v", test.level, test.at, got, test.since)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// Levels at or below this are reported as silence
+	SilenceDB = -120.0
+
+	// Short-term loudness window from EBU R 128
+	shortTermWindow = 3 * time.Second
+)
+
+// ToDBFS converts a linear level (1.0 = full scale) to dBFS, floored at SilenceDB
+func ToDBFS(level float64) float64 {
+	if level <= 0 {
+		return SilenceDB
+	}
+	return math.Max(20*math.Log10(level), SilenceDB)
+}
+
+// biquad is a second-order IIR filter section (transposed direct form II)
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+// process filters one sample
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two ITU-R BS.1770 pre-filter stages for a sample rate:
+// a high shelf modelling the head, then a high-pass (RLB weighting).
+// The bilinear-transform derivation reproduces the published 48 kHz coefficients exactly
+func kWeighting(sampleRate float64) [2]biquad {
+	var stages [2]biquad
+
+	// High shelf: +4 dB above ~1.7 kHz
+	gain, q, fc := 3.99984385397, 0.7071752369554193, 1681.9744509555319
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	k := math.Tan(math.Pi * fc / sampleRate)
+	a0 := 1 + k/q + k*k
+	stages[0] = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// High-pass around 38 Hz
+	q, fc = 0.5003270373253953, 38.13547087613982
+	k = math.Tan(math.Pi * fc / sampleRate)
+	a0 = 1 + k/q + k*k
+	stages[1] = biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return stages
+}
+
+// loudnessBlock is the K-weighted energy of one processed buffer
+type loudnessBlock struct {
+	energy float64 // Sum of squared samples over all channels
+	frames int
+}
+
+// LoudnessMeter measures approximate short-term loudness (LUFS) with K-weighting.
+// All channels are weighted equally, which matches BS.1770 for mono and stereo
+type LoudnessMeter struct {
+	sampleRate   float64
+	windowFrames int
+	filters      [][2]biquad // Per channel
+	blocks       []loudnessBlock
+	totalEnergy  float64
+	totalFrames  int
+}
+
+// NewLoudnessMeter creates a meter averaging over the given window
+func NewLoudnessMeter(sampleRate float64, window time.Duration) *LoudnessMeter {
+	return &LoudnessMeter{
+		sampleRate:   sampleRate,
+		windowFrames: int(window.Seconds() * sampleRate),
+	}
+}
+
+// Process adds a buffer (one slice per channel) to the measurement window
+func (m *LoudnessMeter) Process(buffer [][]float32) {
+	if len(buffer) == 0 {
+		return
+	}
+
+	if len(m.filters) != len(buffer) {
+		m.filters = make([][2]biquad, len(buffer))
+		for ch := range m.filters {
+			m.filters[ch] = kWeighting(m.sampleRate)
+		}
+	}
+
+	block := loudnessBlock{frames: len(buffer[0])}
+	for ch, channel := range buffer {
+		stages := &m.filters[ch]
+		for _, sample := range channel {
+			y := stages[1].process(stages[0].process(float64(sample)))
+			block.energy += y * y
+		}
+	}
+
+	m.blocks = append(m.blocks, block)
+	m.totalEnergy += block.energy
+	m.totalFrames += block.frames
+
+	// Drop the oldest buffers once the window is full
+	for len(m.blocks) > 1 && m.totalFrames-m.blocks[0].frames >= m.windowFrames {
+		m.totalEnergy -= m.blocks[0].energy
+		m.totalFrames -= m.blocks[0].frames
+		m.blocks = m.blocks[1:]
+	}
+}
+
+// LUFS returns the loudness over the window, floored at SilenceDB
+func (m *LoudnessMeter) LUFS() float64 {
+	if m.totalFrames == 0 || m.totalEnergy <= 0 {
+		return SilenceDB
+	}
+	return math.Max(-0.691+10*math.Log10(m.totalEnergy/float64(m.totalFrames)), SilenceDB)
+}
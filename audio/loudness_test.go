@@ -0,0 +1,65 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLoudnessOfSine(t *testing.T) {
+	// A 1 kHz sine peaking at -20 dBFS; K-weighting adds about as much at 1 kHz as the
+	// -0.691 offset takes away, so each channel carrying it contributes -23 LUFS
+	tests := []struct {
+		name      string
+		rate      float64
+		amplitude float64
+		channels  int // Channels carrying the sine; the rest are silent
+		want      float64
+	}{
+		{"stereo at 48 kHz", 48000, 0.1, 2, -20.0},
+		{"stereo at 44.1 kHz", 44100, 0.1, 2, -20.0},
+		{"one channel at 48 kHz", 48000, 0.1, 1, -23.0},
+		{"one channel at 44.1 kHz", 44100, 0.1, 1, -23.0},
+		{"silence", 48000, 0, 2, SilenceDB},
+	}
+	for _, test := range tests {
+		meter := NewLoudnessMeter(test.rate, shortTermWindow)
+		buffer := [][]float32{make([]float32, defaultFramesPerBuffer), make([]float32, defaultFramesPerBuffer)}
+
+		frame := 0
+		for frame < int(4*test.rate) {
+			for i := range buffer[0] {
+				sample := float32(test.amplitude * math.Sin(2*math.Pi*1000*float64(frame)/test.rate))
+				for ch := range buffer {
+					buffer[ch][i] = 0
+					if ch < test.channels {
+						buffer[ch][i] = sample
+					}
+				}
+				frame++
+			}
+			meter.Process(buffer)
+		}
+
+		if got := meter.LUFS(); math.Abs(got-test.want) > 0.1 {
+			t.Errorf("%s: %.2f LUFS, want %.2f", test.name, got, test.want)
+		}
+	}
+}
+
+func TestToDBFS(t *testing.T) {
+	tests := []struct {
+		level float64
+		want  float64
+	}{
+		{1, 0},
+		{0.1, -20},
+		{0.5, -6.0206},
+		{0, SilenceDB},
+		{1e-9, SilenceDB},
+	}
+	for _, test := range tests {
+		if got := ToDBFS(test.level); math.Abs(got-test.want) > 1e-3 {
+			t.Errorf("ToDBFS(%v) = %.4f, want %.4f", test.level, got, test.want)
+		}
+	}
+}
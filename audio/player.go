@@ -3,7 +3,6 @@ package audio
 import (
 	"fmt"
 	"math"
-	"os/exec"
 	"strings"
 	"sync"
 	"time"
@@ -13,60 +12,274 @@ import (
 
 // Player handles audio capture and processing
 type Player struct {
-	paStream         *portaudio.Stream
+	source           Source
+	externalSource   bool // Source was supplied by the caller instead of a PortAudio device
+	paInitialized    bool
 	peakLevel        float64
+	rmsLevel         float64
 	mutex            sync.RWMutex
 	lastAudioTime    time.Time
 	running          bool
 	sensitivity      float64
 	devices          []*portaudio.DeviceInfo
 	currentDeviceIdx int
+	streamConfig     StreamConfig // Requested capture format for PortAudio devices
+	deviceSpec       string       // Device requested by the user (index or name); must exist
+	lastDevice       string       // Device used last time; tried before the heuristics
+	preferredDevice  string       // Device chosen at startup or with CycleDevice; recovery returns to it
+	deviceChanged    func(name string)
+	monitorSource    string // Monitor source set up by Initialize
+	routingEnabled   bool   // Allow changing the sound server routing
+	server           SoundServer
+	appName          string // Application to capture instead of the whole output
+	app              appCapture
+	captureMutex     sync.Mutex // Serializes application capture changes; taken before streamMutex
+	capturedApp      string     // Name of the captured application; guarded by mutex
+	routing          routingChanges
 	updateInfoFunc   func()
+
+	// Stream health; streamMutex serializes opening, starting and stopping the stream
+	streamMutex    sync.Mutex
+	streamState    StreamState
+	lastBufferTime time.Time // When the source last delivered a buffer
+	lastRecovery   time.Time
+	watchdogStop   chan struct{}
+
+	// Spectrum analysis
+	analyzer     *SpectrumAnalyzer
+	spectrumBins int
+	logFrequency bool
+	spectrum     []float64
+	monoBuffer   []float64
+	waveform     []float64
+
+	// Recent raw samples per channel
+	waveformRings []*RingBuffer
+	waveformSize  int
+
+	// Frequency band energies
+	bandAnalyzer *BandAnalyzer
+
+	// Beat detection
+	beatDetector *BeatDetector
+	beatChan     chan BeatEvent
+	beatCallback func(BeatEvent)
+	lastBeat     BeatEvent
+	beatPending  bool
+	pendingBeat  BeatEvent
+
+	// Tempo tracking
+	tempo           *TempoEstimator
+	framesPerBuffer int
+
+	// Automatic gain control; sensitivity is a trim on top of it
+	agc        *AGC
+	agcEnabled bool
+	gain       float64
+
+	// Smoothed dynamics shared by all visualizers
+	envelope *Envelope
+	peakHold *PeakHold
+
+	// Levels before any gain, for metering
+	rawPeak  float64
+	rawRMS   float64
+	loudness *LoudnessMeter
+
+	// Per-channel levels and stereo field
+	channelPeaks []float64
+	stereo       StereoImage
+
+	// Pitch classes and key
+	chroma *ChromaAnalyzer
+
+	// Noise gate and idle detection
+	gate      *NoiseGate
+	idleAfter time.Duration
+
+	// Tee of captured audio and analysis to disk
+	recorder *Recorder
+
+	// Audio/visual sync
+	avOffset     time.Duration // How much later the visuals follow the captured audio
+	inputLatency time.Duration // Smoothed age of buffers when they reach the callback
+	history      []AudioFrame  // Recent frames by capture time, kept while avOffset > 0
+	lastShown    time.Time     // Capture time of the last frame taken from the history
 }
 
-// NewPlayer creates a new audio player
+const (
+	defaultSampleRate      = 44100
+	defaultFramesPerBuffer = 1024
+
+	defaultFFTSize      = 2048
+	defaultSpectrumBins = 32
+
+	// Spectral flux history used for the adaptive beat threshold
+	beatHistory = 1500 * time.Millisecond
+
+	tempoWindow = 8 * time.Second
+
+	defaultWaveformSize = 4096
+)
+
+// NewPlayer creates a new audio player capturing from PortAudio devices
 func NewPlayer() *Player {
-	return &Player{
-		sensitivity:   1.0,
-		lastAudioTime: time.Now(),
+	p := &Player{
+		sensitivity:    1.0,
+		routingEnabled: true,
+		server:         NewPactlServer(),
+		lastAudioTime:  time.Now(),
+		waveformSize:   defaultWaveformSize,
+		spectrumBins:   defaultSpectrumBins,
+		logFrequency:   true,
+		bandAnalyzer:   NewBandAnalyzer(DefaultBands()),
+		beatChan:       make(chan BeatEvent, 16),
+		agc:            NewAGC(defaultAGCTarget, defaultAGCAttack, defaultAGCRelease),
+		agcEnabled:     true,
+		gain:           1.0,
+		envelope:       NewEnvelope(defaultEnvelopeAttack, defaultEnvelopeRelease),
+		peakHold:       NewPeakHold(defaultPeakHoldTime, defaultPeakFallRate),
+		gate:           NewNoiseGate(defaultGateThreshold, defaultGateHysteresis),
+		chroma:         NewChromaAnalyzer(),
+		idleAfter:      defaultIdleAfter,
 	}
+	p.resetAnalysis(defaultSampleRate, defaultFramesPerBuffer)
+	return p
+}
+
+// NewPlayerWithSource creates a player driven by the given source instead of a PortAudio device
+func NewPlayerWithSource(source Source) *Player {
+	p := NewPlayer()
+	p.source = source
+	p.externalSource = true
+	return p
+}
+
+// resetAnalysis rebuilds the rate-dependent analyzers; the caller must hold the mutex or own the player
+func (p *Player) resetAnalysis(sampleRate float64, framesPerBuffer int) {
+	p.analyzer = NewSpectrumAnalyzer(sampleRate, defaultFFTSize, p.spectrumBins)
+	p.analyzer.SetLogScale(p.logFrequency)
+	p.spectrum = make([]float64, len(p.analyzer.Bins()))
+	p.bandAnalyzer = NewBandAnalyzer(p.bandAnalyzer.Bands())
+	p.beatDetector = NewBeatDetector(int(beatHistory.Seconds() * sampleRate / float64(framesPerBuffer)))
+	p.tempo = NewTempoEstimator(sampleRate/float64(framesPerBuffer), tempoWindow)
+	p.loudness = NewLoudnessMeter(sampleRate, shortTermWindow)
+	p.framesPerBuffer = framesPerBuffer
 }
 
 // Initialize sets up the audio system
 func (p *Player) Initialize() error {
+	if p.externalSource {
+		if err := p.openSource(p.source); err != nil {
+			return err
+		}
+		p.startWatchdog()
+		return nil
+	}
+
 	// Automatically detect and set the active audio monitor
-	monitorSource := p.setupCurrentAudioMonitor()
+	monitorSource := ""
+	switch {
+	case p.appName != "" && !p.routingEnabled:
+		return fmt.Errorf("capturing an application needs sound server routing")
+	case p.appName != "":
+		var err error
+		if monitorSource, err = p.captureApp(p.appName); err != nil {
+			return err
+		}
+	case p.routingEnabled:
+		monitorSource = p.setupCurrentAudioMonitor()
+	}
+	p.monitorSource = monitorSource
 
 	err := portaudio.Initialize()
 	if err != nil {
 		return fmt.Errorf("failed to initialize PortAudio: %v", err)
 	}
+	p.paInitialized = true
 
+	devices, err := p.refreshDevices()
+	if err != nil {
+		return err
+	}
+
+	// Verify our monitor source is available
+	p.verifyMonitorSource(monitorSource)
+
+	// An explicit choice wins, then the last used device, then the heuristics
+	var selectedDevice *portaudio.DeviceInfo
+
+	if p.deviceSpec != "" {
+		selectedDevice, err = FindDevice(devices, p.deviceSpec)
+		if err != nil {
+			return err
+		}
+	}
+
+	if selectedDevice == nil && p.lastDevice != "" {
+		selectedDevice = p.findInputDevice(p.lastDevice)
+	}
+
+	if selectedDevice == nil {
+		if monitorSource != "" {
+			fmt.Printf("\nSearching for device matching monitor source: %s\n", monitorSource)
+		}
+		selectedDevice, err = p.bestDevice(monitorSource)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.setCurrentDevice(selectedDevice)
+	if err := p.openStream(selectedDevice); err != nil {
+		return err
+	}
+	p.chooseDevice(selectedDevice)
+	p.startWatchdog()
+	return nil
+}
+
+// refreshDevices enumerates PortAudio devices, keeping the input devices for selection.
+// Returns all devices so indexes match the devices command
+func (p *Player) refreshDevices() ([]*portaudio.DeviceInfo, error) {
 	devices, err := portaudio.Devices()
 	if err != nil {
-		return fmt.Errorf("failed to get audio devices: %v", err)
+		return nil, fmt.Errorf("failed to get audio devices: %v", err)
 	}
 
-	p.devices = make([]*portaudio.DeviceInfo, 0)
+	inputs := make([]*portaudio.DeviceInfo, 0)
 	for _, device := range devices {
 		if device.MaxInputChannels > 0 {
-			p.devices = append(p.devices, device)
+			inputs = append(inputs, device)
 		}
 	}
 
-	if len(p.devices) == 0 {
-		return fmt.Errorf("no input devices found")
+	p.mutex.Lock()
+	p.devices = inputs
+	p.mutex.Unlock()
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input devices found")
 	}
+	return devices, nil
+}
 
-	// Verify our monitor source is available
-	p.verifyMonitorSource(monitorSource)
+// findInputDevice returns the input device with the given name, or nil
+func (p *Player) findInputDevice(name string) *portaudio.DeviceInfo {
+	for _, device := range p.devices {
+		if device.Name == name {
+			return device
+		}
+	}
+	return nil
+}
 
-	// Find the best device - prioritize devices that match our monitor source
+// bestDevice picks an input device heuristically, prioritizing devices that match our monitor source
+func (p *Player) bestDevice(monitorSource string) (*portaudio.DeviceInfo, error) {
 	var selectedDevice *portaudio.DeviceInfo
 
 	// First priority: Look for device that matches our monitor source
 	if monitorSource != "" {
-		fmt.Printf("\nSearching for device matching monitor source: %s\n", monitorSource)
 		for _, device := range p.devices {
 			deviceName := strings.ToLower(device.Name)
 			monitorName := strings.ToLower(monitorSource)
@@ -109,126 +322,425 @@ func (p *Player) Initialize() error {
 		if len(p.devices) > 0 {
 			selectedDevice = p.devices[0]
 		} else {
-			return fmt.Errorf("no audio input devices available")
+			return nil, fmt.Errorf("no audio input devices available")
 		}
 	}
 
-	// Set current device index
+	return selectedDevice, nil
+}
+
+// setCurrentDevice records the index of the selected device
+func (p *Player) setCurrentDevice(selectedDevice *portaudio.DeviceInfo) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	for i, device := range p.devices {
 		if device == selectedDevice {
 			p.currentDeviceIdx = i
 			break
 		}
 	}
+}
 
-	return p.openStream(selectedDevice)
+// chooseDevice makes a device picked at startup or by the user the one recovery returns to,
+// and reports it so it can be remembered. Fallbacks opened by recovery are never reported
+func (p *Player) chooseDevice(device *portaudio.DeviceInfo) {
+	p.preferredDevice = device.Name
+	if p.deviceChanged != nil {
+		p.deviceChanged(device.Name)
+	}
 }
 
 // openStream opens an audio stream with the given device
 func (p *Player) openStream(device *portaudio.DeviceInfo) error {
-	if p.paStream != nil {
-		p.paStream.Close()
-	}
+	return p.openSource(NewPortAudioSourceWithConfig(device, p.streamConfig))
+}
 
-	// Use fewer channels for better compatibility
-	channels := 2
-	if device.MaxInputChannels == 1 {
-		channels = 1
+// openSource closes the current source and opens the given one
+func (p *Player) openSource(source Source) error {
+	if p.source != nil && p.source != source {
+		p.source.Close()
 	}
 
-	streamParams := portaudio.StreamParameters{
-		Input: portaudio.StreamDeviceParameters{
-			Device:   device,
-			Channels: channels,
-			Latency:  device.DefaultLowInputLatency,
-		},
-		SampleRate:      44100,
-		FramesPerBuffer: 1024,
+	if err := source.Open(p.audioCallback); err != nil {
+		return err
 	}
 
-	var err error
-	p.paStream, err = portaudio.OpenStream(streamParams, p.audioCallback)
-	if err != nil {
-		return fmt.Errorf("failed to open audio stream: %v", err)
+	// Rate-dependent analysis must match the new source
+	p.mutex.Lock()
+	p.source = source
+	if p.analyzer.SampleRate() != source.SampleRate() || p.framesPerBuffer != source.FramesPerBuffer() {
+		p.resetAnalysis(source.SampleRate(), source.FramesPerBuffer())
 	}
+	p.agc.Reset()
+	p.resetLatency(source)
+	p.mutex.Unlock()
 
 	return nil
 }
 
-// audioCallback processes incoming audio data
-func (p *Player) audioCallback(inputBuffer [][]float32) {
+// audioCallback processes incoming audio data, timestamped with its capture time
+func (p *Player) audioCallback(inputBuffer [][]float32, captured time.Time) {
+	now := time.Now()
+
+	p.mutex.Lock()
+	p.lastBufferTime = now
+	p.measureLatency(now.Sub(captured))
+	p.mutex.Unlock()
+
+	p.ProcessBuffer(inputBuffer, captured)
+}
+
+// ProcessBuffer runs a buffer captured at the given time through the analysis chain;
+// offline tools can call it directly with synthetic timestamps
+func (p *Player) ProcessBuffer(inputBuffer [][]float32, timestamp time.Time) {
+	p.process(inputBuffer, timestamp, true)
+}
+
+// process runs a buffer through the analysis chain and, if record is set, tees it to the recorder
+func (p *Player) process(inputBuffer [][]float32, timestamp time.Time, record bool) {
 	if len(inputBuffer) == 0 {
 		return
 	}
 
 	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	beat, isBeat := p.analyze(inputBuffer, timestamp)
+	var snapshot AudioFrame
+	recorder := p.recorder
+	if !record {
+		recorder = nil
+	}
+	if p.avOffset > 0 || (recorder != nil && recorder.logsFeatures()) {
+		snapshot = p.snapshot(timestamp)
+		snapshot.Beat = isBeat
+		snapshot.BeatStrength = beat.Strength
+	}
+	if p.avOffset > 0 {
+		delayed := snapshot
+		delayed.Waveform = append([]float64(nil), p.waveform...)
+		p.rememberFrame(delayed)
+	}
+	if recorder != nil {
+		var frame *AudioFrame
+		if recorder.logsFeatures() {
+			frame = &snapshot
+		}
+		recorder.add(inputBuffer, p.analyzer.SampleRate(), frame, timestamp)
+	}
+	callback := p.beatCallback
+	p.mutex.Unlock()
+
+	// Publish beats outside the lock so listeners can query the player
+	if isBeat {
+		select {
+		case p.beatChan <- beat:
+		default:
+			// Nobody is draining the channel; drop the event
+		}
+		if callback != nil {
+			callback(beat)
+		}
+	}
+}
 
+// analyze updates all audio features from a buffer; the caller must hold the mutex
+func (p *Player) analyze(inputBuffer [][]float32, now time.Time) (BeatEvent, bool) {
 	peak := float64(0)
+	sumSquares := float64(0)
 	sampleCount := 0
 
-	for _, channel := range inputBuffer {
+	if len(p.channelPeaks) != len(inputBuffer) {
+		p.channelPeaks = make([]float64, len(inputBuffer))
+	}
+
+	for ch, channel := range inputBuffer {
+		channelPeak := float64(0)
 		for _, sample := range channel {
 			absSample := math.Abs(float64(sample))
-			if absSample > peak {
-				peak = absSample
+			if absSample > channelPeak {
+				channelPeak = absSample
 			}
+			sumSquares += absSample * absSample
 			sampleCount++
 		}
+		p.channelPeaks[ch] = channelPeak
+		peak = math.Max(peak, channelPeak)
 	}
 
-	// Apply sensitivity
-	peak *= p.sensitivity
+	rms := float64(0)
+	if sampleCount > 0 {
+		rms = math.Sqrt(sumSquares / float64(sampleCount))
+	}
 
-	// Clamp to reasonable range
-	if peak > 1.0 {
-		peak = 1.0
+	if peak > 0.0001 {
+		p.lastAudioTime = now
 	}
 
-	p.peakLevel = peak
+	// Meter the signal as captured, independent of AGC and sensitivity
+	p.rawPeak = peak
+	p.rawRMS = rms
+	p.loudness.Process(inputBuffer)
 
-	if peak > 0.0001 {
-		p.lastAudioTime = time.Now()
+	// Hiss below the gate neither animates patterns nor trains the AGC
+	gateOpen := p.gate.Process(ToDBFS(rms), now)
+
+	// One gain for levels, spectrum and bands: AGC (when enabled) times the sensitivity trim
+	dt := float64(len(inputBuffer[0])) / p.analyzer.SampleRate()
+	agcGain := p.agc.Gain()
+	if gateOpen {
+		agcGain = p.agc.Process(rms, dt)
+	}
+	p.gain = p.sensitivity
+	if p.agcEnabled {
+		p.gain *= agcGain
+	}
+	if !gateOpen {
+		p.gain = 0
+	}
+
+	p.peakLevel = math.Min(peak*p.gain, 1.0)
+	p.rmsLevel = math.Min(rms*p.gain, 1.0)
+	p.envelope.Process(p.peakLevel, dt)
+	p.peakHold.Process(p.peakLevel, dt)
+
+	// Per-channel levels share the gain; the stereo field relaxes to centered while gated
+	for ch := range p.channelPeaks {
+		p.channelPeaks[ch] = math.Min(p.channelPeaks[ch]*p.gain, 1.0)
+	}
+	image := StereoImage{}
+	if gateOpen {
+		image = measureStereo(inputBuffer)
+	}
+	p.stereo = smoothStereo(p.stereo, image, dt)
+	p.stereo.Mid = math.Min(p.stereo.Mid*p.gain, 1.0)
+	p.stereo.Side = math.Min(p.stereo.Side*p.gain, 1.0)
+
+	// Keep the raw samples of every channel for waveform visualizers
+	if len(p.waveformRings) != len(inputBuffer) {
+		p.waveformRings = make([]*RingBuffer, len(inputBuffer))
+		for i := range p.waveformRings {
+			p.waveformRings[i] = NewRingBuffer(p.waveformSize)
+		}
+	}
+	for i, channel := range inputBuffer {
+		p.waveformRings[i].Write(channel)
 	}
+
+	// Run the windowed FFT on a mono mixdown of the buffer
+	mono := p.mixDown(inputBuffer)
+	p.analyzer.Process(mono)
+
+	if len(p.waveform) != len(mono) {
+		p.waveform = make([]float64, len(mono))
+	}
+	copy(p.waveform, mono)
+
+	bins := p.analyzer.Bins()
+	if len(p.spectrum) != len(bins) {
+		p.spectrum = make([]float64, len(bins))
+	}
+	for i, magnitude := range bins {
+		p.spectrum[i] = math.Min(magnitude*p.gain, 1.0)
+	}
+
+	p.bandAnalyzer.Process(p.analyzer.Magnitudes(), p.analyzer.BinHz(), p.gain)
+
+	if gateOpen {
+		p.chroma.Process(p.analyzer.Magnitudes(), p.analyzer.BinHz(), dt)
+	} else {
+		p.chroma.Decay(dt)
+	}
+
+	// Keep the detector history current but ignore onsets in gated noise
+	beat, isBeat := p.beatDetector.Process(p.analyzer.Magnitudes(), now)
+	isBeat = isBeat && gateOpen
+	if isBeat {
+		p.lastBeat = beat
+
+		// Remember the strongest beat until the next frame is taken
+		if !p.beatPending || beat.Strength > p.pendingBeat.Strength {
+			p.pendingBeat = beat
+		}
+		p.beatPending = true
+	}
+
+	onset := 0.0
+	if gateOpen {
+		onset = p.beatDetector.Flux()
+	}
+	p.tempo.Process(onset, now)
+
+	return beat, isBeat
 }
 
-// Start begins audio capture
+// mixDown averages all channels into a reusable mono buffer
+func (p *Player) mixDown(inputBuffer [][]float32) []float64 {
+	frames := len(inputBuffer[0])
+	if cap(p.monoBuffer) < frames {
+		p.monoBuffer = make([]float64, frames)
+	}
+	mono := p.monoBuffer[:frames]
+
+	for i := range mono {
+		sum := 0.0
+		for _, channel := range inputBuffer {
+			if i < len(channel) {
+				sum += float64(channel[i])
+			}
+		}
+		mono[i] = sum / float64(len(inputBuffer))
+	}
+
+	return mono
+}
+
+// Start begins audio capture. If a capture device fails to start, the player keeps
+// running in the failed state and the watchdog retries it; IsCapturing tells the cases apart
 func (p *Player) Start() error {
-	if p.paStream == nil {
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+	return p.start()
+}
+
+// start begins audio capture; the caller must hold the stream mutex
+func (p *Player) start() error {
+	if p.source == nil {
 		return fmt.Errorf("audio stream not initialized")
 	}
 
-	err := p.paStream.Start()
-	if err != nil {
-		return fmt.Errorf("failed to start audio stream: %v", err)
+	// Count the stall timeout from now, not from the last buffer before a pause
+	p.mutex.Lock()
+	p.lastBufferTime = time.Now()
+	p.mutex.Unlock()
+
+	if err := p.source.Start(); err != nil {
+		// Files and generators can't be reopened
+		if p.externalSource {
+			return err
+		}
+		// Keep running so the watchdog retries the device
+		p.running = true
+		p.setStreamState(StreamFailed)
+		return err
 	}
 
 	p.running = true
+	p.setStreamState(StreamRunning)
 	return nil
 }
 
 // Stop stops audio capture
 func (p *Player) Stop() {
-	if p.paStream != nil && p.running {
-		p.paStream.Stop()
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+	p.stop()
+}
+
+// stop stops audio capture; the caller must hold the stream mutex
+func (p *Player) stop() {
+	if p.source != nil && p.running {
+		p.source.Stop()
 		p.running = false
+		p.setStreamState(StreamStopped)
 	}
 }
 
 // Restart stops and starts audio capture
 func (p *Player) Restart() {
-	p.Stop()
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
+	p.stop()
 	time.Sleep(100 * time.Millisecond)
-	p.Start()
+	p.start()
+}
+
+// Cleanup cleans up audio resources. Returns the error of finishing the recording, the
+// only step whose failure loses user data
+func (p *Player) Cleanup() error {
+	// Let an application capture in progress finish, so restoring the routing undoes it
+	p.captureMutex.Lock()
+	defer p.captureMutex.Unlock()
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
+	if p.watchdogStop != nil {
+		close(p.watchdogStop)
+		p.watchdogStop = nil
+	}
+
+	p.stop()
+	if p.source != nil {
+		p.source.Close()
+	}
+	err := p.StopRecording()
+	if p.paInitialized {
+		portaudio.Terminate()
+		p.paInitialized = false
+	}
+
+	// Leave the sound server as we found it
+	p.restoreRouting()
+	return err
+}
+
+// StartRecording tees captured audio to a WAV file and/or the analysis of every
+// buffer to an NDJSON feature log; pass "" to skip either
+func (p *Player) StartRecording(wavPath, featuresPath string) error {
+	if wavPath == "" && featuresPath == "" {
+		return nil
+	}
+	p.StopRecording()
+
+	recorder, err := NewRecorder(wavPath, featuresPath)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	p.recorder = recorder
+	p.mutex.Unlock()
+	return nil
+}
+
+// StopRecording finishes the recording files, if recording
+func (p *Player) StopRecording() error {
+	p.mutex.Lock()
+	recorder := p.recorder
+	p.recorder = nil
+	p.mutex.Unlock()
+
+	if recorder == nil {
+		return nil
+	}
+	return recorder.Close()
+}
+
+// IsRecording returns true while captured audio is written to disk
+func (p *Player) IsRecording() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.recorder != nil
 }
 
-// Cleanup cleans up audio resources
-func (p *Player) Cleanup() {
-	p.Stop()
-	if p.paStream != nil {
-		p.paStream.Close()
+// GetDroppedBuffers returns how many buffers the recording lost because the disk fell behind
+func (p *Player) GetDroppedBuffers() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.recorder == nil {
+		return 0
+	}
+	return p.recorder.Dropped()
+}
+
+// GetRecordingError returns the write error that stopped the recording, if any
+func (p *Player) GetRecordingError() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.recorder == nil {
+		return nil
 	}
-	portaudio.Terminate()
+	return p.recorder.Err()
 }
 
 // IsCapturing returns true if currently capturing audio
@@ -243,19 +755,375 @@ func (p *Player) GetPeakLevel() float64 {
 	return p.peakLevel
 }
 
+// GetRMSLevel returns the RMS level of the latest buffer after sensitivity
+func (p *Player) GetRMSLevel() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.rmsLevel
+}
+
+// GetPeakDBFS returns the peak of the latest buffer in dBFS, before any gain
+func (p *Player) GetPeakDBFS() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return ToDBFS(p.rawPeak)
+}
+
+// GetRMSDBFS returns the RMS level of the latest buffer in dBFS, before any gain
+func (p *Player) GetRMSDBFS() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return ToDBFS(p.rawRMS)
+}
+
+// GetLoudnessLUFS returns the approximate short-term (3 s) loudness in LUFS, before any gain
+func (p *Player) GetLoudnessLUFS() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.loudness.LUFS()
+}
+
+// SetGateThreshold sets the noise gate opening level in dBFS (RMS, before gain) and its hysteresis in dB;
+// a threshold at or below SilenceDB disables the gate
+func (p *Player) SetGateThreshold(thresholdDB, hysteresisDB float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.gate.SetThreshold(thresholdDB, hysteresisDB)
+}
+
+// GetGateThreshold returns the noise gate opening level in dBFS
+func (p *Player) GetGateThreshold() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.gate.Threshold()
+}
+
+// IsGateOpen returns whether audio is currently above the noise gate
+func (p *Player) IsGateOpen() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.gate.IsOpen()
+}
+
+// GetSilenceDuration returns how long the noise gate has been closed, 0 while audio is playing
+func (p *Player) GetSilenceDuration() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.silenceDuration()
+}
+
+// silenceDuration returns how long the gate has been closed; the caller must hold the mutex
+func (p *Player) silenceDuration() time.Duration {
+	if p.gate.IsOpen() {
+		return 0
+	}
+	return time.Since(p.gate.Since())
+}
+
+// SetIdleAfter sets how long silence must last before IsSilent reports true; 0 disables idle detection
+func (p *Player) SetIdleAfter(d time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.idleAfter = d
+}
+
+// IsSilent returns true once the gate has been closed for the idle timeout
+func (p *Player) IsSilent() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.isSilent()
+}
+
+// isSilent checks the idle timeout; the caller must hold the mutex
+func (p *Player) isSilent() bool {
+	return p.idleAfter > 0 && p.silenceDuration() >= p.idleAfter
+}
+
+// GetChannelLevels returns the peak of each channel in the latest buffer after gain
+func (p *Player) GetChannelLevels() []float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	levels := make([]float64, len(p.channelPeaks))
+	copy(levels, p.channelPeaks)
+	return levels
+}
+
+// GetBalance returns the smoothed left/right balance from -1.0 (left) to 1.0 (right)
+func (p *Player) GetBalance() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.stereo.Balance
+}
+
+// GetCorrelation returns the smoothed L/R phase correlation: 1.0 mono, 0 wide, -1.0 out of phase
+func (p *Player) GetCorrelation() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.stereo.Correlation
+}
+
+// GetMidSide returns the mid (L+R) and side (L-R) RMS levels after gain.
+// For a vectorscope, plot the per-channel samples from GetWaveform
+func (p *Player) GetMidSide() (mid, side float64) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.stereo.Mid, p.stereo.Side
+}
+
+// GetChroma returns the short-term energy of the 12 pitch classes (C first), strongest = 1.0
+func (p *Player) GetChroma() [12]float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.chroma.Chroma()
+}
+
+// GetPitchClass returns the dominant pitch class (0 = C, see NoteNames), or -1 when none stands out
+func (p *Player) GetPitchClass() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.chroma.PitchClass()
+}
+
+// GetKey returns the estimated musical key of the last several seconds
+func (p *Player) GetKey() Key {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.chroma.Key()
+}
+
+// GetEnvelopeLevel returns the peak level smoothed by the attack/release envelope
+func (p *Player) GetEnvelopeLevel() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.envelope.Value()
+}
+
+// GetPeakHold returns the held peak level, which falls slowly after the hold time
+func (p *Player) GetPeakHold() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.peakHold.Value()
+}
+
+// SetEnvelopeTimes changes the level envelope attack and release times in seconds
+func (p *Player) SetEnvelopeTimes(attack, release float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.envelope.SetTimes(attack, release)
+}
+
+// SetPeakHold changes how long peaks are held (seconds) and how fast they fall afterwards (per second)
+func (p *Player) SetPeakHold(hold, fallRate float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.peakHold.SetTimes(hold, fallRate)
+}
+
+// GetAudioFrame returns a snapshot of all audio features for the next visual frame,
+// delayed by the A/V offset
+func (p *Player) GetAudioFrame() AudioFrame {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	display := now.Add(-p.avOffset)
+
+	var frame AudioFrame
+	if p.avOffset > 0 && len(p.history) > 0 {
+		frame = p.delayedFrame(display)
+		frame.Waveform = append([]float64(nil), frame.Waveform...)
+		p.beatPending = false
+	} else {
+		frame = p.snapshot(now)
+		frame.Waveform = make([]float64, len(p.waveform))
+		copy(frame.Waveform, p.waveform)
+
+		// Each beat is reported in exactly one frame
+		if p.beatPending {
+			frame.Beat = true
+			frame.BeatStrength = p.pendingBeat.Strength
+			p.beatPending = false
+		}
+	}
+	frame.BeatPhase = p.tempo.Phase(display)
+
+	return frame
+}
+
+// snapshot copies the current features without the waveform or beat flag; the caller must hold the mutex
+func (p *Player) snapshot(now time.Time) AudioFrame {
+	frame := AudioFrame{
+		Peak:       p.peakLevel,
+		RMS:        p.rmsLevel,
+		Envelope:   p.envelope.Value(),
+		PeakHold:   p.peakHold.Value(),
+		Silent:     p.isSilent(),
+		Channels:   make([]float64, len(p.channelPeaks)),
+		Stereo:     p.stereo,
+		Chroma:     p.chroma.Chroma(),
+		PitchClass: p.chroma.PitchClass(),
+		Key:        p.chroma.Key(),
+		Bands:      make(map[string]float64),
+		Spectrum:   make([]float64, len(p.spectrum)),
+		Tempo:      p.tempo.BPM(),
+		BeatPhase:  p.tempo.Phase(now),
+		Timestamp:  now,
+	}
+	copy(frame.Spectrum, p.spectrum)
+	copy(frame.Channels, p.channelPeaks)
+	for i, band := range p.bandAnalyzer.Bands() {
+		frame.Bands[band.Name] = p.bandAnalyzer.Energies()[i]
+	}
+	return frame
+}
+
+// GetWaveform returns a snapshot of the most recent samples of every channel, oldest first
+func (p *Player) GetWaveform() [][]float32 {
+	p.mutex.RLock()
+	rings := p.waveformRings
+	p.mutex.RUnlock()
+
+	waveform := make([][]float32, len(rings))
+	for i, ring := range rings {
+		waveform[i] = ring.Snapshot()
+	}
+	return waveform
+}
+
+// SetWaveformSize sets how many recent samples per channel GetWaveform keeps
+func (p *Player) SetWaveformSize(size int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Build a fresh slice so snapshots taken outside the lock stay valid
+	p.waveformSize = size
+	rings := make([]*RingBuffer, len(p.waveformRings))
+	for i := range rings {
+		rings[i] = NewRingBuffer(size)
+	}
+	p.waveformRings = rings
+}
+
+// GetSpectrum returns a copy of the current magnitude spectrum (0.0 - 1.0 per bin)
+func (p *Player) GetSpectrum() []float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	spectrum := make([]float64, len(p.spectrum))
+	copy(spectrum, p.spectrum)
+	return spectrum
+}
+
+// SetSpectrumBins sets how many frequency bins GetSpectrum returns
+func (p *Player) SetSpectrumBins(numBins int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.spectrumBins = numBins
+	p.analyzer.SetBinCount(numBins)
+	p.spectrum = make([]float64, len(p.analyzer.Bins()))
+}
+
+// SetLogFrequency toggles logarithmic frequency grouping of the spectrum bins
+func (p *Player) SetLogFrequency(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.logFrequency = enabled
+	p.analyzer.SetLogScale(enabled)
+}
+
+// GetBandEnergies returns the smoothed energy (0.0 - 1.0) of every named frequency band
+func (p *Player) GetBandEnergies() map[string]float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	energies := make(map[string]float64)
+	for i, band := range p.bandAnalyzer.Bands() {
+		energies[band.Name] = p.bandAnalyzer.Energies()[i]
+	}
+	return energies
+}
+
+// GetBandEnergy returns the smoothed energy of a single named band, or 0 if unknown
+func (p *Player) GetBandEnergy(name string) float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for i, band := range p.bandAnalyzer.Bands() {
+		if band.Name == name {
+			return p.bandAnalyzer.Energies()[i]
+		}
+	}
+	return 0
+}
+
+// SetBandCrossovers moves the crossover frequencies between sub-bass, bass, mids and highs
+func (p *Player) SetBandCrossovers(subBass, bass, mids float64) error {
+	bands, err := BandsFromCrossovers(subBass, bass, mids)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.bandAnalyzer = NewBandAnalyzer(bands)
+	return nil
+}
+
+// Beats returns a channel receiving detected beats; events are dropped if it is not drained
+func (p *Player) Beats() <-chan BeatEvent {
+	return p.beatChan
+}
+
+// SetBeatCallback sets a function called from the audio thread on every beat; keep it fast
+func (p *Player) SetBeatCallback(fn func(BeatEvent)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.beatCallback = fn
+}
+
+// GetLastBeat returns the most recently detected beat
+func (p *Player) GetLastBeat() BeatEvent {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.lastBeat
+}
+
+// GetTempo returns the estimated tempo in BPM, or 0 if no steady beat has been found
+func (p *Player) GetTempo() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.tempo.BPM()
+}
+
+// GetBeatPhase returns the current position within the beat (0.0 on the beat, approaching 1.0 before the next),
+// shifted by the A/V offset
+func (p *Player) GetBeatPhase() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.tempo.Phase(time.Now().Add(-p.avOffset))
+}
+
 // GetVolumePercentage returns peak level as percentage
 func (p *Player) GetVolumePercentage() float64 {
 	return p.GetPeakLevel() * 100
 }
 
-// GetSensitivity returns current sensitivity setting
+// GetSensitivity returns current sensitivity setting (a trim on top of the AGC when enabled)
 func (p *Player) GetSensitivity() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 	return p.sensitivity
 }
 
 // IncreaseSensitivity increases audio sensitivity
 func (p *Player) IncreaseSensitivity() {
+	p.mutex.Lock()
 	p.sensitivity = math.Min(p.sensitivity+0.1, 5.0)
+	p.mutex.Unlock()
 	if p.updateInfoFunc != nil {
 		p.updateInfoFunc()
 	}
@@ -263,14 +1131,122 @@ func (p *Player) IncreaseSensitivity() {
 
 // DecreaseSensitivity decreases audio sensitivity
 func (p *Player) DecreaseSensitivity() {
+	p.mutex.Lock()
 	p.sensitivity = math.Max(p.sensitivity-0.1, 0.1)
+	p.mutex.Unlock()
 	if p.updateInfoFunc != nil {
 		p.updateInfoFunc()
 	}
 }
 
+// ToggleAGC switches automatic gain control on/off and returns the new state
+func (p *Player) ToggleAGC() bool {
+	p.mutex.Lock()
+	p.agcEnabled = !p.agcEnabled
+	enabled := p.agcEnabled
+	p.mutex.Unlock()
+	if p.updateInfoFunc != nil {
+		p.updateInfoFunc()
+	}
+	return enabled
+}
+
+// SetAGCEnabled switches automatic gain control on/off
+func (p *Player) SetAGCEnabled(enabled bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.agcEnabled = enabled
+}
+
+// IsAGCEnabled returns whether automatic gain control is active
+func (p *Player) IsAGCEnabled() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.agcEnabled
+}
+
+// SetAGCTimes changes the attack and release times of the AGC envelope in seconds
+func (p *Player) SetAGCTimes(attack, release float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if attack > 0 && release > 0 {
+		p.agc.attack = attack
+		p.agc.release = release
+	}
+}
+
+// GetGain returns the total gain applied to the latest buffer (AGC times sensitivity)
+func (p *Player) GetGain() float64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.gain
+}
+
+// SetDevice selects the capture device by index (as listed by the devices command) or name;
+// Initialize fails if it does not exist. Call before Initialize
+func (p *Player) SetDevice(spec string) {
+	p.deviceSpec = spec
+}
+
+// SetLastDevice names the previously used device, preferred over the heuristics when present
+func (p *Player) SetLastDevice(name string) {
+	p.lastDevice = name
+}
+
+// SetDeviceChangedFunc registers a function called with the device name whenever a capture device
+// is chosen, at startup or with CycleDevice; not for temporary fallbacks while the device is gone
+func (p *Player) SetDeviceChangedFunc(fn func(name string)) {
+	p.deviceChanged = fn
+}
+
+// SetStreamConfig sets the capture format requested from PortAudio devices; call before Initialize
+func (p *Player) SetStreamConfig(config StreamConfig) {
+	p.streamConfig = config
+}
+
+// GetStreamConfig returns the format actually delivered by the current source
+func (p *Player) GetStreamConfig() StreamConfig {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.source == nil {
+		return StreamConfig{}
+	}
+	return StreamConfig{
+		SampleRate:      p.source.SampleRate(),
+		FramesPerBuffer: p.source.FramesPerBuffer(),
+		Channels:        p.source.Channels(),
+	}
+}
+
+// GetSourceError returns the error that stopped the current source, e.g. a corrupt file
+func (p *Player) GetSourceError() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if reporter, ok := p.source.(errorReporter); ok {
+		return reporter.Err()
+	}
+	return nil
+}
+
+// IsFinished returns true once the source has ended on its own, e.g. a file played without looping
+func (p *Player) IsFinished() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	reporter, ok := p.source.(finishReporter)
+	return ok && reporter.Finished()
+}
+
 // GetCurrentDeviceName returns name of current audio device
 func (p *Player) GetCurrentDeviceName() string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.externalSource && p.source != nil {
+		return p.source.Name()
+	}
 	if p.currentDeviceIdx >= 0 && p.currentDeviceIdx < len(p.devices) {
 		return p.devices[p.currentDeviceIdx].Name
 	}
@@ -279,33 +1255,36 @@ func (p *Player) GetCurrentDeviceName() string {
 
 // CycleDevice switches to next available input device
 func (p *Player) CycleDevice() {
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
 	if len(p.devices) <= 1 {
 		return
 	}
 
 	wasRunning := p.running
-	prevDeviceIdx := p.currentDeviceIdx
-	p.currentDeviceIdx = (p.currentDeviceIdx + 1) % len(p.devices)
-	nextDevice := p.devices[p.currentDeviceIdx]
+	prevDevice := p.devices[p.currentDeviceIdx]
+	nextDevice := p.devices[(p.currentDeviceIdx+1)%len(p.devices)]
 
 	// Stop current stream
 	if wasRunning {
-		p.Stop()
+		p.stop()
 	}
 
 	// Open stream with new device
 	if err := p.openStream(nextDevice); err != nil {
-		p.currentDeviceIdx = prevDeviceIdx
-		p.openStream(p.devices[prevDeviceIdx])
+		p.openStream(prevDevice)
 		if wasRunning {
-			p.Start()
+			p.start()
 		}
 		return
 	}
+	p.setCurrentDevice(nextDevice)
+	p.chooseDevice(nextDevice)
 
 	// Restart if was running
 	if wasRunning {
-		p.Start()
+		p.start()
 	}
 
 	if p.updateInfoFunc != nil {
@@ -332,48 +1311,20 @@ func (p *Player) SetUpdateInfoFunc(fn func()) {
 
 // setupCurrentAudioMonitor automatically configures PulseAudio monitor
 func (p *Player) setupCurrentAudioMonitor() string {
-	// Get list of sinks and find the one that's RUNNING
-	cmd := exec.Command("pactl", "list", "sinks", "short")
-	output, err := cmd.Output()
+	sinks, err := p.server.ListSinks()
 	if err != nil {
 		return p.fallbackMonitorSetup()
 	}
 
-	lines := strings.Split(string(output), "\n")
-	var runningSink string
-
-	// First try: Find RUNNING sink
-	for _, line := range lines {
-		if strings.Contains(line, "RUNNING") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				runningSink = parts[1] // Get sink name
-				break
-			}
-		}
-	}
-
-	// Second try: Find any available sink
-	if runningSink == "" {
-		for _, line := range lines {
-			if strings.TrimSpace(line) != "" {
-				parts := strings.Fields(line)
-				if len(parts) >= 2 {
-					runningSink = parts[1]
-					break
-				}
-			}
-		}
-	}
+	// First and second try: the RUNNING sink, else any sink
+	runningSink := findPlayingSink(sinks)
 
 	// Third try: Get default sink
 	if runningSink == "" {
-		cmd = exec.Command("pactl", "get-default-sink")
-		output, err = cmd.Output()
+		runningSink, err = p.server.DefaultSink()
 		if err != nil {
 			return p.fallbackMonitorSetup()
 		}
-		runningSink = strings.TrimSpace(string(output))
 	}
 
 	if runningSink == "" {
@@ -381,9 +1332,8 @@ func (p *Player) setupCurrentAudioMonitor() string {
 	}
 
 	// Set the monitor of the running sink as default source
-	monitorSource := runningSink + ".monitor"
-	cmd = exec.Command("pactl", "set-default-source", monitorSource)
-	err = cmd.Run()
+	monitorSource := p.monitorOf(runningSink)
+	err = p.setDefaultSource(monitorSource)
 	if err != nil {
 		return p.setupAlternativeMonitor(runningSink)
 	}
@@ -397,6 +1347,33 @@ func (p *Player) setupCurrentAudioMonitor() string {
 	return monitorSource
 }
 
+// findPlayingSink returns the first RUNNING sink, else the first sink, else ""
+func findPlayingSink(sinks []SinkInfo) string {
+	for _, sink := range sinks {
+		if sink.State == "RUNNING" {
+			return sink.Name
+		}
+	}
+	for _, sink := range sinks {
+		if sink.Name != "" {
+			return sink.Name
+		}
+	}
+	return ""
+}
+
+// monitorOf returns the monitor source of a sink
+func (p *Player) monitorOf(sink string) string {
+	if sources, err := p.server.ListSources(); err == nil {
+		for _, source := range sources {
+			if source.MonitorOf == sink {
+				return source.Name
+			}
+		}
+	}
+	return sink + ".monitor"
+}
+
 // verifyMonitorSource checks if the configured monitor source is available
 func (p *Player) verifyMonitorSource(monitorSource string) {
 	// Silently verify - no debug output needed
@@ -408,52 +1385,45 @@ func (p *Player) forceMonitorSource(monitorSource string) {
 		return
 	}
 
-	// Try to move all recording streams to our monitor source
-	cmd := exec.Command("pactl", "list", "source-outputs", "short")
-	output, err := cmd.Output()
+	sources, err := p.server.ListSources()
 	if err != nil {
 		return
 	}
+	sourceNames := make(map[int]string, len(sources))
+	for _, source := range sources {
+		sourceNames[source.Index] = source.Name
+	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
+	// Try to move all recording streams to our monitor source
+	outputs, err := p.server.ListSourceOutputs()
+	if err != nil {
+		return
+	}
+	for _, output := range outputs {
+		from := sourceNames[output.Source]
+		if from == monitorSource {
 			continue
 		}
-		parts := strings.Fields(line)
-		if len(parts) >= 1 {
-			sourceOutputId := parts[0]
-			// Move this source output to our monitor
-			moveCmd := exec.Command("pactl", "move-source-output", sourceOutputId, monitorSource)
-			moveCmd.Run()
-		}
+		// Move this source output to our monitor, remembering its source for restore
+		p.moveSourceOutput(output.Index, from, monitorSource)
 	}
 }
 
-// fallbackMonitorSetup tries to set up audio capture when pactl commands fail
+// fallbackMonitorSetup tries to set up audio capture when the sinks can't be queried
 func (p *Player) fallbackMonitorSetup() string {
-	// Try to find any .monitor source
-	cmd := exec.Command("pactl", "list", "sources", "short")
-	output, err := cmd.Output()
+	// Try to find any monitor source
+	sources, err := p.server.ListSources()
 	if err != nil {
 		return ""
 	}
 
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
+	for _, source := range sources {
+		if source.MonitorOf == "" {
 			continue
 		}
-		parts := strings.Fields(line)
-		if len(parts) >= 2 {
-			sourceName := parts[1]
-			if strings.HasSuffix(sourceName, ".monitor") {
-				// Try to set it as default
-				setCmd := exec.Command("pactl", "set-default-source", sourceName)
-				if err := setCmd.Run(); err == nil {
-					return sourceName
-				}
-			}
+		// Try to set it as default
+		if err := p.setDefaultSource(source.Name); err == nil {
+			return source.Name
 		}
 	}
 
@@ -463,15 +1433,13 @@ func (p *Player) fallbackMonitorSetup() string {
 // setupAlternativeMonitor tries alternative ways to setup the monitor
 func (p *Player) setupAlternativeMonitor(sinkName string) string {
 	// Try loading a loopback module as fallback
-	cmd := exec.Command("pactl", "load-module", "module-loopback", fmt.Sprintf("source=%s.monitor", sinkName))
-	if err := cmd.Run(); err == nil {
+	if err := p.loadModule("module-loopback", fmt.Sprintf("source=%s.monitor", sinkName)); err == nil {
 		time.Sleep(1 * time.Second)
 		return sinkName + ".monitor"
 	}
 
 	// Try generic loopback
-	cmd = exec.Command("pactl", "load-module", "module-loopback")
-	if err := cmd.Run(); err == nil {
+	if err := p.loadModule("module-loopback"); err == nil {
 		time.Sleep(1 * time.Second)
 		return sinkName + ".monitor"
 	}
@@ -0,0 +1,196 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Buffers queued for the writer goroutine (~6 s at 1024 frames and 44.1 kHz)
+const recordQueueSize = 256
+
+// recordJob is one buffer and, when logging features, its analysis
+type recordJob struct {
+	buffer     [][]float32
+	sampleRate float64
+	features   *FeatureRecord
+}
+
+// Recorder tees captured buffers to a WAV file and their analysis to an
+// NDJSON feature log. Disk writes happen on a separate goroutine so the audio
+// callback never waits for them; buffers are dropped if the disk falls behind.
+// When the capture format changes (other device, renegotiated stream) or the file
+// reaches the 4 GiB WAV limit, the audio continues in a new file: session.wav,
+// session-2.wav, ...
+type Recorder struct {
+	wavPath  string
+	wav      *WAVWriter
+	wavPart  int // Number of WAV files started
+	features *os.File
+	writer   *bufio.Writer
+	encoder  *json.Encoder
+
+	start   time.Time    // Timestamp of the first buffer
+	dropped atomic.Int64 // Read by the UI while the audio callback counts
+	jobs    chan recordJob
+	done    chan struct{}
+
+	// First write error; nothing more is written after it
+	mutex sync.Mutex
+	err   error
+}
+
+// NewRecorder creates a recorder; either path may be empty to skip that output.
+// The WAV file takes the sample rate and channel count of the first buffer
+func NewRecorder(wavPath, featuresPath string) (*Recorder, error) {
+	r := &Recorder{
+		wavPath: wavPath,
+		jobs:    make(chan recordJob, recordQueueSize),
+		done:    make(chan struct{}),
+	}
+
+	if featuresPath != "" {
+		file, err := os.Create(featuresPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", featuresPath, err)
+		}
+		r.features = file
+		r.writer = bufio.NewWriter(file)
+		r.encoder = json.NewEncoder(r.writer)
+	}
+
+	go r.run()
+	return r, nil
+}
+
+// logsFeatures returns whether the recorder writes a feature log
+func (r *Recorder) logsFeatures() bool {
+	return r.encoder != nil
+}
+
+// add queues a buffer and the frame analyzed from it (nil without a feature log).
+// Called from the audio callback, so it never blocks
+func (r *Recorder) add(buffer [][]float32, sampleRate float64, frame *AudioFrame, timestamp time.Time) {
+	if r.start.IsZero() {
+		r.start = timestamp
+	}
+
+	job := recordJob{sampleRate: sampleRate}
+	if r.wavPath != "" {
+		// The source may reuse its buffer once the callback returns
+		job.buffer = make([][]float32, len(buffer))
+		for ch := range buffer {
+			job.buffer[ch] = append([]float32(nil), buffer[ch]...)
+		}
+	}
+	if frame != nil {
+		record := NewFeatureRecord(*frame, r.start)
+		job.features = &record
+	}
+
+	select {
+	case r.jobs <- job:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// partPath returns the path of the nth WAV file of the recording
+func (r *Recorder) partPath(part int) string {
+	if part <= 1 {
+		return r.wavPath
+	}
+	ext := filepath.Ext(r.wavPath)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(r.wavPath, ext), part, ext)
+}
+
+// run writes queued jobs until the queue is closed, stopping at the first error
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	// Only this goroutine sets err, so it may read it without the mutex
+	for job := range r.jobs {
+		if r.err != nil {
+			continue
+		}
+		if err := r.write(job); err != nil {
+			r.mutex.Lock()
+			r.err = err
+			r.mutex.Unlock()
+		}
+	}
+}
+
+// write writes one job to the WAV file and the feature log
+func (r *Recorder) write(job recordJob) error {
+	if job.buffer != nil {
+		formatChanged := r.wav != nil && (r.wav.sampleRate != job.sampleRate || r.wav.channels != len(job.buffer))
+		if formatChanged || (r.wav != nil && !r.wav.Fits(len(job.buffer[0]))) {
+			// The header can't describe two formats or sizes past 4 GiB; continue in the next file
+			err := r.wav.Close()
+			r.wav = nil
+			if err != nil {
+				return err
+			}
+		}
+		if r.wav == nil {
+			r.wavPart++
+			wav, err := NewWAVWriter(r.partPath(r.wavPart), job.sampleRate, len(job.buffer))
+			if err != nil {
+				return err
+			}
+			r.wav = wav
+		}
+		if err := r.wav.Write(job.buffer); err != nil {
+			return err
+		}
+	}
+
+	if job.features != nil {
+		if err := r.encoder.Encode(job.features); err != nil {
+			return fmt.Errorf("failed to write feature log: %v", err)
+		}
+	}
+	return nil
+}
+
+// Err returns the write error that stopped the recording, if any
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Dropped returns how many buffers were lost because the writer fell behind
+func (r *Recorder) Dropped() int {
+	return int(r.dropped.Load())
+}
+
+// Close waits for queued buffers to be written and finishes both files.
+// No more buffers may be added
+func (r *Recorder) Close() error {
+	close(r.jobs)
+	<-r.done
+
+	err := r.err
+	if r.wav != nil {
+		if closeErr := r.wav.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if r.features != nil {
+		if flushErr := r.writer.Flush(); err == nil && flushErr != nil {
+			err = fmt.Errorf("failed to write feature log: %v", flushErr)
+		}
+		if closeErr := r.features.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderRotatesOnFormatChange(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(filepath.Join(dir, "session.wav"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stereo := [][]float32{make([]float32, 256), make([]float32, 256)}
+	mono := [][]float32{make([]float32, 128)}
+	now := time.Unix(0, 0)
+	recorder.add(stereo, 44100, nil, now)
+	recorder.add(stereo, 44100, nil, now)
+	recorder.add(mono, 48000, nil, now)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		rate     float64
+		channels int
+		frames   int
+	}{
+		{"session.wav", 44100, 2, 512},
+		{"session-2.wav", 48000, 1, 128},
+	}
+	for _, test := range tests {
+		decoder, err := openDecoder(filepath.Join(dir, test.file))
+		if err != nil {
+			t.Fatalf("%s: %v", test.file, err)
+		}
+		defer decoder.close()
+		if decoder.sampleRate() != test.rate || decoder.channels() != test.channels {
+			t.Errorf("%s: %v Hz, %d channels, want %v Hz, %d channels", test.file, decoder.sampleRate(), decoder.channels(), test.rate, test.channels)
+		}
+
+		buffer := make([][]float32, decoder.channels())
+		for ch := range buffer {
+			buffer[ch] = make([]float32, 64)
+		}
+		frames := 0
+		for {
+			n, err := decoder.read(buffer)
+			frames += n
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", test.file, err)
+			}
+		}
+		if frames != test.frames {
+			t.Errorf("%s: %d frames, want %d", test.file, frames, test.frames)
+		}
+	}
+}
+
+func TestRecorderReportsWriteError(t *testing.T) {
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "missing", "session.wav"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.add([][]float32{make([]float32, 256)}, 44100, nil, time.Unix(0, 0))
+	deadline := time.Now().Add(time.Second)
+	for recorder.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if recorder.Err() == nil {
+		t.Fatal("Err = nil while recording into a missing directory")
+	}
+	if err := recorder.Close(); err == nil {
+		t.Error("Close = nil, want the write error")
+	}
+}
+
+func TestRecorderRotatesAtSizeLimit(t *testing.T) {
+	limit := maxWAVDataSize
+	maxWAVDataSize = 2 * 256 * 4
+	t.Cleanup(func() { maxWAVDataSize = limit })
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(filepath.Join(dir, "session.wav"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mono := [][]float32{make([]float32, 256)}
+	for i := 0; i < 5; i++ {
+		recorder.add(mono, 44100.4, nil, time.Unix(0, 0))
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, want := range map[string]int64{"session.wav": 512, "session-2.wav": 512, "session-3.wav": 256} {
+		decoder, err := openDecoder(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		frames, err := decodeAll(decoder)
+		decoder.close()
+		if err != io.EOF || int64(len(frames)) != want {
+			t.Errorf("%s: %d frames (%v), want %d", file, len(frames), err, want)
+		}
+	}
+}
+
+func TestWAVHeaderRoundsFractionalRate(t *testing.T) {
+	writer, err := NewWAVWriter(filepath.Join(t.TempDir(), "rate.wav"), 44099.6, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	header := writer.header()
+	rate := binary.LittleEndian.Uint32(header[24:])
+	byteRate := binary.LittleEndian.Uint32(header[28:])
+	if rate != 44100 || byteRate != 44100*8 {
+		t.Errorf("rate %d, byte rate %d, want 44100 and %d", rate, byteRate, 44100*8)
+	}
+}
+
+func TestStallSilenceIsNotRecorded(t *testing.T) {
+	source, err := NewGeneratorSource(SignalPink, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := NewPlayerWithSource(source)
+	path := filepath.Join(t.TempDir(), "session.wav")
+	if err := player.StartRecording(path, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := [][]float32{make([]float32, 256), make([]float32, 256)}
+	player.ProcessBuffer(buffer, time.Now())
+	player.feedSilence(time.Now())
+	if player.GetPeakLevel() != 0 {
+		t.Errorf("peak after silence = %v, want 0", player.GetPeakLevel())
+	}
+	if err := player.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := openDecoder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.close()
+	samples, err := decodeAll(decoder)
+	if err != io.EOF {
+		t.Fatalf("decoding ended with %v, want io.EOF", err)
+	}
+	if frames := len(samples) / 2; frames != 256 {
+		t.Errorf("recorded %d frames, want only the 256 captured ones", frames)
+	}
+}
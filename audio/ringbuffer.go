@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"sync"
+)
+
+// RingBuffer keeps the most recent samples of a single channel
+type RingBuffer struct {
+	mutex sync.Mutex
+	data  []float32
+	pos   int
+	full  bool
+}
+
+// NewRingBuffer creates a ring buffer holding up to size samples
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{
+		data: make([]float32, size),
+	}
+}
+
+// Write appends samples, overwriting the oldest ones once full
+func (r *RingBuffer) Write(samples []float32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Only the tail can survive if more samples arrive than fit
+	if len(samples) > len(r.data) {
+		samples = samples[len(samples)-len(r.data):]
+	}
+
+	for len(samples) > 0 {
+		n := copy(r.data[r.pos:], samples)
+		samples = samples[n:]
+		r.pos += n
+		if r.pos == len(r.data) {
+			r.pos = 0
+			r.full = true
+		}
+	}
+}
+
+// Snapshot returns a copy of the buffered samples, oldest first
+func (r *RingBuffer) Snapshot() []float32 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.full {
+		snapshot := make([]float32, r.pos)
+		copy(snapshot, r.data[:r.pos])
+		return snapshot
+	}
+
+	snapshot := make([]float32, len(r.data))
+	n := copy(snapshot, r.data[r.pos:])
+	copy(snapshot[n:], r.data[:r.pos])
+	return snapshot
+}
+
+// Size returns the capacity of the buffer in samples
+func (r *RingBuffer) Size() int {
+	return len(r.data)
+}
@@ -0,0 +1,77 @@
+package audio
+
+// routingChanges records what the player changed on the sound server so Cleanup can undo it
+type routingChanges struct {
+	previousSource string         // Default source before we first changed it
+	sourceChanged  bool           // Whether we changed the default source
+	movedOutputs   map[int]string // Source output index -> source it was recording from
+	modules        []int          // Indexes of modules we loaded, in load order
+}
+
+// SetRoutingEnabled controls whether Initialize may change the default source,
+// move recording streams or load loopback modules
+func (p *Player) SetRoutingEnabled(enabled bool) {
+	p.routingEnabled = enabled
+}
+
+// SetSoundServer replaces the pactl backend, e.g. with a FakeSoundServer
+func (p *Player) SetSoundServer(server SoundServer) {
+	p.server = server
+}
+
+// setDefaultSource changes the default source, remembering the original one
+func (p *Player) setDefaultSource(name string) error {
+	previous, _ := p.server.DefaultSource()
+	if err := p.server.SetDefaultSource(name); err != nil {
+		return err
+	}
+	if !p.routing.sourceChanged && previous != name {
+		p.routing.previousSource = previous
+		p.routing.sourceChanged = true
+	}
+	return nil
+}
+
+// moveSourceOutput moves a recording stream to another source, remembering where it came from
+func (p *Player) moveSourceOutput(index int, from, to string) error {
+	if err := p.server.MoveSourceOutput(index, to); err != nil {
+		return err
+	}
+	if p.routing.movedOutputs == nil {
+		p.routing.movedOutputs = make(map[int]string)
+	}
+	if _, moved := p.routing.movedOutputs[index]; !moved && from != "" {
+		p.routing.movedOutputs[index] = from
+	}
+	return nil
+}
+
+// loadModule loads a sound server module and remembers its index for unloading
+func (p *Player) loadModule(name string, args ...string) error {
+	index, err := p.server.LoadModule(name, args...)
+	if err != nil {
+		return err
+	}
+	p.routing.modules = append(p.routing.modules, index)
+	return nil
+}
+
+// restoreRouting undoes the recorded sound server changes; streams or modules
+// that disappeared in the meantime are skipped
+func (p *Player) restoreRouting() {
+	p.releaseApp()
+
+	for index, source := range p.routing.movedOutputs {
+		p.server.MoveSourceOutput(index, source)
+	}
+
+	if p.routing.sourceChanged && p.routing.previousSource != "" {
+		p.server.SetDefaultSource(p.routing.previousSource)
+	}
+
+	for i := len(p.routing.modules) - 1; i >= 0; i-- {
+		p.server.UnloadModule(p.routing.modules[i])
+	}
+
+	p.routing = routingChanges{}
+}
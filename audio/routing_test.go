@@ -0,0 +1,190 @@
+package audio
+
+import (
+	"errors"
+	"testing"
+)
+
+func newRoutingPlayer(server *FakeSoundServer) *Player {
+	p := NewPlayer()
+	p.SetSoundServer(server)
+	return p
+}
+
+func TestFindPlayingSink(t *testing.T) {
+	tests := []struct {
+		name  string
+		sinks []SinkInfo
+		want  string
+	}{
+		{"running over idle", []SinkInfo{{Name: "speakers", State: "SUSPENDED"}, {Name: "usb", State: "RUNNING"}}, "usb"},
+		{"first when nothing plays", []SinkInfo{{Name: "speakers", State: "SUSPENDED"}, {Name: "usb", State: "IDLE"}}, "speakers"},
+		{"none", nil, ""},
+	}
+	for _, test := range tests {
+		if got := findPlayingSink(test.sinks); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSetupMonitorPrefersPlayingSink(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("speakers", "SUSPENDED")
+	server.AddSink("usb", "RUNNING")
+	server.AddSource("mic", "IDLE")
+	server.DefaultSourceName = "mic"
+	p := newRoutingPlayer(server)
+
+	if got := p.setupCurrentAudioMonitor(); got != "usb.monitor" {
+		t.Fatalf("monitor = %q, want usb.monitor", got)
+	}
+	if server.DefaultSourceName != "usb.monitor" {
+		t.Errorf("default source = %q, want usb.monitor", server.DefaultSourceName)
+	}
+}
+
+func TestSetupMonitorFallbackWithoutSinks(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSource("mic", "IDLE")
+	server.AddSink("hdmi", "SUSPENDED")
+	server.Errors["ListSinks"] = errors.New("pactl timed out")
+	p := newRoutingPlayer(server)
+
+	if got := p.setupCurrentAudioMonitor(); got != "hdmi.monitor" {
+		t.Fatalf("monitor = %q, want hdmi.monitor", got)
+	}
+	if server.DefaultSourceName != "hdmi.monitor" {
+		t.Errorf("default source = %q, want hdmi.monitor", server.DefaultSourceName)
+	}
+}
+
+func TestSetupMonitorLoopbackWhenDefaultSourceRefused(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("usb", "RUNNING")
+	server.Errors["SetDefaultSource"] = errors.New("access denied")
+	p := newRoutingPlayer(server)
+
+	if got := p.setupCurrentAudioMonitor(); got != "usb.monitor" {
+		t.Fatalf("monitor = %q, want usb.monitor", got)
+	}
+	if len(server.Modules) != 1 || server.Modules[1] != "module-loopback source=usb.monitor" {
+		t.Errorf("modules = %v, want one loopback from usb.monitor", server.Modules)
+	}
+}
+
+func TestForceMonitorSourceMovesRecordingStreams(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("usb", "RUNNING")
+	server.AddSource("mic", "IDLE")
+	server.AddSourceOutput("obs", "mic")
+	server.AddSourceOutput("recorder", "usb.monitor")
+	p := newRoutingPlayer(server)
+
+	p.forceMonitorSource("usb.monitor")
+
+	monitor := server.findSource("usb.monitor").Index
+	for _, output := range server.SourceOutputs {
+		if output.Source != monitor {
+			t.Errorf("%s records from source %d, want %d", output.App, output.Source, monitor)
+		}
+	}
+	if len(p.routing.movedOutputs) != 1 || p.routing.movedOutputs[0] != "mic" {
+		t.Errorf("moved outputs = %v, want only obs from mic", p.routing.movedOutputs)
+	}
+}
+
+func TestRestoreRoutingUndoesChanges(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("usb", "RUNNING")
+	server.AddSource("mic", "IDLE")
+	server.DefaultSourceName = "mic"
+	server.AddSourceOutput("obs", "mic")
+	p := newRoutingPlayer(server)
+
+	p.setupCurrentAudioMonitor()
+	if err := p.loadModule("module-loopback"); err != nil {
+		t.Fatal(err)
+	}
+	p.restoreRouting()
+
+	if server.DefaultSourceName != "mic" {
+		t.Errorf("default source = %q, want mic", server.DefaultSourceName)
+	}
+	if mic := server.findSource("mic").Index; server.SourceOutputs[0].Source != mic {
+		t.Errorf("obs records from source %d, want %d", server.SourceOutputs[0].Source, mic)
+	}
+	if len(server.Modules) != 0 {
+		t.Errorf("modules left loaded: %v", server.Modules)
+	}
+}
+
+func TestAppCaptureRestore(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("usb", "RUNNING")
+	server.AddSource("mic", "IDLE")
+	server.AddSinkInput("Spotify", "usb")
+	server.AddSinkInput("Firefox", "usb")
+	p := newRoutingPlayer(server)
+	usb := server.findSink("usb").Index
+
+	monitor, err := p.captureApp("spot")
+	if err != nil || monitor != captureSinkName+".monitor" {
+		t.Fatalf("captureApp = %q, %v", monitor, err)
+	}
+	capture := server.findSink(captureSinkName).Index
+	if server.SinkInputs[0].Sink != capture || server.SinkInputs[1].Sink != usb {
+		t.Fatalf("sink inputs = %v, want only Spotify on the capture sink", server.SinkInputs)
+	}
+
+	// Capturing again replaces the capture sink; the app must still return to its speakers
+	if _, err := p.captureApp("spot"); err != nil {
+		t.Fatal(err)
+	}
+	p.restoreRouting()
+
+	for _, input := range server.SinkInputs {
+		if input.Sink != usb {
+			t.Errorf("%s plays on sink %d, want %d", input.App, input.Sink, usb)
+		}
+	}
+	if len(server.Modules) != 0 || server.findSink(captureSinkName) != nil {
+		t.Errorf("capture sink left behind: modules %v, sinks %v", server.Modules, server.Sinks)
+	}
+}
+
+func TestCycleAppConcurrentPresses(t *testing.T) {
+	server := NewFakeSoundServer()
+	server.AddSink("usb", "RUNNING")
+	server.AddSource("mic", "IDLE")
+	server.AddSinkInput("Spotify", "usb")
+	server.AddSinkInput("Firefox", "usb")
+	p := newRoutingPlayer(server)
+
+	// Two quick presses step through both applications
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := p.CycleApp()
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := p.GetApp(); got != "Firefox" {
+		t.Errorf("captured %q after two presses, want Firefox", got)
+	}
+	if len(server.Modules) != 2 {
+		t.Errorf("modules = %v, want one capture sink and its loopback", server.Modules)
+	}
+
+	if err := p.CaptureApp(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Modules) != 0 || server.findSink(captureSinkName) != nil {
+		t.Errorf("capture sink left behind: modules %v, sinks %v", server.Modules, server.Sinks)
+	}
+}
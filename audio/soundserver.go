@@ -0,0 +1,59 @@
+package audio
+
+// SinkInfo describes a sound server output
+type SinkInfo struct {
+	Index int
+	Name  string
+	State string // RUNNING, IDLE or SUSPENDED
+}
+
+// SourceInfo describes a sound server input, including sink monitors
+type SourceInfo struct {
+	Index     int
+	Name      string
+	State     string
+	MonitorOf string // Name of the monitored sink, empty for real inputs
+}
+
+// SourceOutputInfo describes a recording stream
+type SourceOutputInfo struct {
+	Index  int
+	Source int // Index of the source being recorded
+	App    string
+}
+
+// SinkInputInfo describes a playback stream
+type SinkInputInfo struct {
+	Index int
+	Sink  int // Index of the sink it plays to
+	App   string
+}
+
+// SoundServer controls the PulseAudio/PipeWire routing the player relies on
+type SoundServer interface {
+	// ListSinks returns all outputs
+	ListSinks() ([]SinkInfo, error)
+	// ListSources returns all inputs, including monitors
+	ListSources() ([]SourceInfo, error)
+	// ListSourceOutputs returns all recording streams
+	ListSourceOutputs() ([]SourceOutputInfo, error)
+	// ListSinkInputs returns all playback streams
+	ListSinkInputs() ([]SinkInputInfo, error)
+
+	// DefaultSink returns the name of the default output
+	DefaultSink() (string, error)
+	// DefaultSource returns the name of the default input
+	DefaultSource() (string, error)
+	// SetDefaultSource changes the default input
+	SetDefaultSource(name string) error
+
+	// MoveSourceOutput moves a recording stream to the named source
+	MoveSourceOutput(index int, source string) error
+	// MoveSinkInput moves a playback stream to the named sink
+	MoveSinkInput(index int, sink string) error
+
+	// LoadModule loads a module with key=value arguments and returns its index
+	LoadModule(name string, args ...string) (int, error)
+	// UnloadModule unloads a module by index
+	UnloadModule(index int) error
+}
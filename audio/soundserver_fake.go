@@ -0,0 +1,260 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FakeSoundServer is an in-memory SoundServer for tests and demos. Fill in the
+// initial state, script failures through Errors and inspect Calls afterwards
+type FakeSoundServer struct {
+	Sinks             []SinkInfo
+	Sources           []SourceInfo
+	SourceOutputs     []SourceOutputInfo
+	SinkInputs        []SinkInputInfo
+	DefaultSinkName   string
+	DefaultSourceName string
+
+	// Loaded modules by index, as "name arg1 arg2"
+	Modules    map[int]string
+	nextModule int
+	nullSinks  map[int]string // Module index -> sink created by module-null-sink
+
+	// Indexes are never reused, like on a real server
+	nextSink   int
+	nextSource int
+
+	// Errors makes the named method (e.g. "SetDefaultSource") fail
+	Errors map[string]error
+
+	// Calls records every call as "Method arg1 arg2"
+	Calls []string
+}
+
+// NewFakeSoundServer creates an empty fake sound server
+func NewFakeSoundServer() *FakeSoundServer {
+	return &FakeSoundServer{
+		Modules:    make(map[int]string),
+		Errors:     make(map[string]error),
+		nextModule: 1,
+		nullSinks:  make(map[int]string),
+	}
+}
+
+// AddSink adds a sink and its monitor source
+func (f *FakeSoundServer) AddSink(name, state string) {
+	f.nextSink++
+	f.Sinks = append(f.Sinks, SinkInfo{Index: f.nextSink - 1, Name: name, State: state})
+	f.nextSource++
+	f.Sources = append(f.Sources, SourceInfo{Index: f.nextSource - 1, Name: name + ".monitor", State: state, MonitorOf: name})
+	if f.DefaultSinkName == "" {
+		f.DefaultSinkName = name
+	}
+}
+
+// AddSource adds a real input
+func (f *FakeSoundServer) AddSource(name, state string) {
+	f.nextSource++
+	f.Sources = append(f.Sources, SourceInfo{Index: f.nextSource - 1, Name: name, State: state})
+	if f.DefaultSourceName == "" {
+		f.DefaultSourceName = name
+	}
+}
+
+// AddSourceOutput adds a recording stream on the named source
+func (f *FakeSoundServer) AddSourceOutput(app, source string) {
+	output := SourceOutputInfo{Index: len(f.SourceOutputs), Source: -1, App: app}
+	if s := f.findSource(source); s != nil {
+		output.Source = s.Index
+	}
+	f.SourceOutputs = append(f.SourceOutputs, output)
+}
+
+// AddSinkInput adds a playback stream from an application on the named sink
+func (f *FakeSoundServer) AddSinkInput(app, sink string) {
+	input := SinkInputInfo{Index: len(f.SinkInputs), Sink: -1, App: app}
+	if s := f.findSink(sink); s != nil {
+		input.Sink = s.Index
+	}
+	f.SinkInputs = append(f.SinkInputs, input)
+}
+
+// removeSink removes a sink and its monitor source
+func (f *FakeSoundServer) removeSink(name string) {
+	sinks := f.Sinks[:0]
+	for _, sink := range f.Sinks {
+		if sink.Name != name {
+			sinks = append(sinks, sink)
+		}
+	}
+	f.Sinks = sinks
+
+	sources := f.Sources[:0]
+	for _, source := range f.Sources {
+		if source.MonitorOf != name {
+			sources = append(sources, source)
+		}
+	}
+	f.Sources = sources
+}
+
+// call records a call and returns the scripted error for the method, if any
+func (f *FakeSoundServer) call(method string, args ...string) error {
+	f.Calls = append(f.Calls, strings.TrimSpace(method+" "+strings.Join(args, " ")))
+	return f.Errors[method]
+}
+
+// findSource looks a source up by name or index
+func (f *FakeSoundServer) findSource(source string) *SourceInfo {
+	for i := range f.Sources {
+		if f.Sources[i].Name == source || strconv.Itoa(f.Sources[i].Index) == source {
+			return &f.Sources[i]
+		}
+	}
+	return nil
+}
+
+// findSink looks a sink up by name or index
+func (f *FakeSoundServer) findSink(sink string) *SinkInfo {
+	for i := range f.Sinks {
+		if f.Sinks[i].Name == sink || strconv.Itoa(f.Sinks[i].Index) == sink {
+			return &f.Sinks[i]
+		}
+	}
+	return nil
+}
+
+// ListSinks returns all outputs
+func (f *FakeSoundServer) ListSinks() ([]SinkInfo, error) {
+	if err := f.call("ListSinks"); err != nil {
+		return nil, err
+	}
+	return append([]SinkInfo(nil), f.Sinks...), nil
+}
+
+// ListSources returns all inputs, including monitors
+func (f *FakeSoundServer) ListSources() ([]SourceInfo, error) {
+	if err := f.call("ListSources"); err != nil {
+		return nil, err
+	}
+	return append([]SourceInfo(nil), f.Sources...), nil
+}
+
+// ListSourceOutputs returns all recording streams
+func (f *FakeSoundServer) ListSourceOutputs() ([]SourceOutputInfo, error) {
+	if err := f.call("ListSourceOutputs"); err != nil {
+		return nil, err
+	}
+	return append([]SourceOutputInfo(nil), f.SourceOutputs...), nil
+}
+
+// ListSinkInputs returns all playback streams
+func (f *FakeSoundServer) ListSinkInputs() ([]SinkInputInfo, error) {
+	if err := f.call("ListSinkInputs"); err != nil {
+		return nil, err
+	}
+	return append([]SinkInputInfo(nil), f.SinkInputs...), nil
+}
+
+// DefaultSink returns the name of the default output
+func (f *FakeSoundServer) DefaultSink() (string, error) {
+	if err := f.call("DefaultSink"); err != nil {
+		return "", err
+	}
+	return f.DefaultSinkName, nil
+}
+
+// DefaultSource returns the name of the default input
+func (f *FakeSoundServer) DefaultSource() (string, error) {
+	if err := f.call("DefaultSource"); err != nil {
+		return "", err
+	}
+	return f.DefaultSourceName, nil
+}
+
+// SetDefaultSource changes the default input
+func (f *FakeSoundServer) SetDefaultSource(name string) error {
+	if err := f.call("SetDefaultSource", name); err != nil {
+		return err
+	}
+	if f.findSource(name) == nil {
+		return fmt.Errorf("no such source: %s", name)
+	}
+	f.DefaultSourceName = name
+	return nil
+}
+
+// MoveSourceOutput moves a recording stream to the named source
+func (f *FakeSoundServer) MoveSourceOutput(index int, source string) error {
+	if err := f.call("MoveSourceOutput", strconv.Itoa(index), source); err != nil {
+		return err
+	}
+	target := f.findSource(source)
+	if target == nil {
+		return fmt.Errorf("no such source: %s", source)
+	}
+	for i := range f.SourceOutputs {
+		if f.SourceOutputs[i].Index == index {
+			f.SourceOutputs[i].Source = target.Index
+			return nil
+		}
+	}
+	return fmt.Errorf("no such source output: %d", index)
+}
+
+// MoveSinkInput moves a playback stream to the named sink
+func (f *FakeSoundServer) MoveSinkInput(index int, sink string) error {
+	if err := f.call("MoveSinkInput", strconv.Itoa(index), sink); err != nil {
+		return err
+	}
+	target := f.findSink(sink)
+	if target == nil {
+		return fmt.Errorf("no such sink: %s", sink)
+	}
+	for i := range f.SinkInputs {
+		if f.SinkInputs[i].Index == index {
+			f.SinkInputs[i].Sink = target.Index
+			return nil
+		}
+	}
+	return fmt.Errorf("no such sink input: %d", index)
+}
+
+// LoadModule loads a module with key=value arguments and returns its index;
+// module-null-sink creates the sink named by its sink_name argument
+func (f *FakeSoundServer) LoadModule(name string, args ...string) (int, error) {
+	if err := f.call("LoadModule", append([]string{name}, args...)...); err != nil {
+		return 0, err
+	}
+	index := f.nextModule
+	f.nextModule++
+	f.Modules[index] = strings.TrimSpace(name + " " + strings.Join(args, " "))
+
+	if name == "module-null-sink" {
+		for _, arg := range args {
+			if sink, found := strings.CutPrefix(arg, "sink_name="); found {
+				f.AddSink(sink, "IDLE")
+				f.nullSinks[index] = sink
+			}
+		}
+	}
+	return index, nil
+}
+
+// UnloadModule unloads a module by index
+func (f *FakeSoundServer) UnloadModule(index int) error {
+	if err := f.call("UnloadModule", strconv.Itoa(index)); err != nil {
+		return err
+	}
+	if _, ok := f.Modules[index]; !ok {
+		return fmt.Errorf("no such module: %d", index)
+	}
+	delete(f.Modules, index)
+
+	if sink, ok := f.nullSinks[index]; ok {
+		f.removeSink(sink)
+		delete(f.nullSinks, index)
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PactlServer drives PulseAudio or PipeWire through the pactl command line tool.
+// It parses `pactl --format=json` (pactl 16+) and falls back to the short text listings
+type PactlServer struct {
+	mutex sync.Mutex
+	json  *bool // Whether --format=json works; nil until first tried; guarded by mutex
+}
+
+// NewPactlServer creates a pactl backed sound server
+func NewPactlServer() *PactlServer {
+	return &PactlServer{}
+}
+
+// run executes pactl and returns its trimmed output; errors keep pactl's message
+func (s *PactlServer) run(args ...string) (string, error) {
+	output, err := exec.Command("pactl", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("failed to run pactl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to run pactl %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// listJSON decodes `pactl --format=json list <kind>` into v; returns false when
+// JSON output is unavailable so the caller falls back to text. Only a pactl that
+// rejects the option is remembered as lacking JSON; other failures, like the
+// server restarting, are retried on the next call
+func (s *PactlServer) listJSON(kind string, v interface{}) bool {
+	if s.lacksJSON() {
+		return false
+	}
+
+	output, err := s.run("--format=json", "list", kind)
+	if err != nil {
+		if rejectsJSONFormat(err) {
+			s.setJSON(false)
+		}
+		return false
+	}
+	if !strings.HasPrefix(output, "[") {
+		// Succeeded without honoring the option
+		s.setJSON(false)
+		return false
+	}
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		return false
+	}
+	s.setJSON(true)
+	return true
+}
+
+// lacksJSON returns whether pactl is known not to support --format=json
+func (s *PactlServer) lacksJSON() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.json != nil && !*s.json
+}
+
+// setJSON remembers whether pactl supports --format=json
+func (s *PactlServer) setJSON(supported bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.json == nil {
+		s.json = &supported
+	}
+}
+
+// rejectsJSONFormat returns whether pactl failed because it does not know --format=json
+func rejectsJSONFormat(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	message := strings.ToLower(string(exitErr.Stderr))
+	for _, rejection := range []string{"unrecognized option", "unknown option", "invalid option", "invalid format"} {
+		if strings.Contains(message, rejection) {
+			return true
+		}
+	}
+	return false
+}
+
+// listShort returns the tab separated fields of `pactl list <kind> short`
+func (s *PactlServer) listShort(kind string) ([][]string, error) {
+	output, err := s.run("list", kind, "short")
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rows = append(rows, strings.Split(line, "\t"))
+	}
+	return rows, nil
+}
+
+// field returns a field of a short listing row, or "" if missing
+func field(row []string, i int) string {
+	if i < len(row) {
+		return strings.TrimSpace(row[i])
+	}
+	return ""
+}
+
+// jsonSink is a sink as reported by pactl --format=json
+type jsonSink struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// ListSinks returns all outputs
+func (s *PactlServer) ListSinks() ([]SinkInfo, error) {
+	var sinks []jsonSink
+	if s.listJSON("sinks", &sinks) {
+		infos := make([]SinkInfo, 0, len(sinks))
+		for _, sink := range sinks {
+			infos = append(infos, SinkInfo{Index: sink.Index, Name: sink.Name, State: sink.State})
+		}
+		return infos, nil
+	}
+
+	// index, name, driver, sample spec, state
+	rows, err := s.listShort("sinks")
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SinkInfo, 0, len(rows))
+	for _, row := range rows {
+		index, err := strconv.Atoi(field(row, 0))
+		if err != nil {
+			continue
+		}
+		infos = append(infos, SinkInfo{Index: index, Name: field(row, 1), State: field(row, 4)})
+	}
+	return infos, nil
+}
+
+// jsonSource is a source as reported by pactl --format=json
+type jsonSource struct {
+	Index         int     `json:"index"`
+	Name          string  `json:"name"`
+	State         string  `json:"state"`
+	MonitorOfSink *string `json:"monitor_of_sink"`
+}
+
+// ListSources returns all inputs, including monitors
+func (s *PactlServer) ListSources() ([]SourceInfo, error) {
+	var sources []jsonSource
+	if s.listJSON("sources", &sources) {
+		infos := make([]SourceInfo, 0, len(sources))
+		for _, source := range sources {
+			info := SourceInfo{Index: source.Index, Name: source.Name, State: source.State}
+			if source.MonitorOfSink != nil && *source.MonitorOfSink != "n/a" {
+				info.MonitorOf = *source.MonitorOfSink
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	}
+
+	// index, name, driver, sample spec, state; monitors are only recognizable by name
+	rows, err := s.listShort("sources")
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SourceInfo, 0, len(rows))
+	for _, row := range rows {
+		index, err := strconv.Atoi(field(row, 0))
+		if err != nil {
+			continue
+		}
+		info := SourceInfo{Index: index, Name: field(row, 1), State: field(row, 4)}
+		info.MonitorOf, _ = strings.CutSuffix(info.Name, ".monitor")
+		if info.MonitorOf == info.Name {
+			info.MonitorOf = ""
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// jsonSourceOutput is a recording stream as reported by pactl --format=json
+type jsonSourceOutput struct {
+	Index      int               `json:"index"`
+	Source     int               `json:"source"`
+	Properties map[string]string `json:"properties"`
+}
+
+// ListSourceOutputs returns all recording streams
+func (s *PactlServer) ListSourceOutputs() ([]SourceOutputInfo, error) {
+	var outputs []jsonSourceOutput
+	if s.listJSON("source-outputs", &outputs) {
+		infos := make([]SourceOutputInfo, 0, len(outputs))
+		for _, output := range outputs {
+			infos = append(infos, SourceOutputInfo{Index: output.Index, Source: output.Source, App: output.Properties["application.name"]})
+		}
+		return infos, nil
+	}
+
+	// index, source, client, driver, sample spec; the short listing has no application names
+	rows, err := s.listShort("source-outputs")
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SourceOutputInfo, 0, len(rows))
+	for _, row := range rows {
+		index, err := strconv.Atoi(field(row, 0))
+		if err != nil {
+			continue
+		}
+		source, _ := strconv.Atoi(field(row, 1))
+		infos = append(infos, SourceOutputInfo{Index: index, Source: source})
+	}
+	return infos, nil
+}
+
+// jsonSinkInput is a playback stream as reported by pactl --format=json
+type jsonSinkInput struct {
+	Index      int               `json:"index"`
+	Sink       int               `json:"sink"`
+	Properties map[string]string `json:"properties"`
+}
+
+// ListSinkInputs returns all playback streams
+func (s *PactlServer) ListSinkInputs() ([]SinkInputInfo, error) {
+	var inputs []jsonSinkInput
+	if s.listJSON("sink-inputs", &inputs) {
+		infos := make([]SinkInputInfo, 0, len(inputs))
+		for _, input := range inputs {
+			infos = append(infos, SinkInputInfo{Index: input.Index, Sink: input.Sink, App: input.Properties["application.name"]})
+		}
+		return infos, nil
+	}
+
+	// The short listing has no application names, so parse the long one:
+	//   Sink Input #42
+	//           Sink: 1
+	//                   application.name = "Spotify"
+	output, err := s.run("list", "sink-inputs")
+	if err != nil {
+		return nil, err
+	}
+	var infos []SinkInputInfo
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if rest, found := strings.CutPrefix(line, "Sink Input #"); found {
+			index, err := strconv.Atoi(rest)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, SinkInputInfo{Index: index, Sink: -1})
+			continue
+		}
+		if len(infos) == 0 {
+			continue
+		}
+		current := &infos[len(infos)-1]
+		if rest, found := strings.CutPrefix(line, "Sink:"); found {
+			if sink, err := strconv.Atoi(strings.TrimSpace(rest)); err == nil {
+				current.Sink = sink
+			}
+		} else if rest, found := strings.CutPrefix(line, "application.name ="); found && current.App == "" {
+			current.App = strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return infos, nil
+}
+
+// defaultDevice returns the default sink or source ("sink"/"source")
+func (s *PactlServer) defaultDevice(kind string) (string, error) {
+	if name, err := s.run("get-default-" + kind); err == nil {
+		return name, nil
+	}
+
+	// Older pactl versions only report it in the server info
+	info, err := s.run("info")
+	if err != nil {
+		return "", err
+	}
+	prefix := "Default " + strings.ToUpper(kind[:1]) + kind[1:] + ":"
+	for _, line := range strings.Split(info, "\n") {
+		if name, found := strings.CutPrefix(strings.TrimSpace(line), prefix); found {
+			return strings.TrimSpace(name), nil
+		}
+	}
+	return "", fmt.Errorf("no default %s reported", kind)
+}
+
+// DefaultSink returns the name of the default output
+func (s *PactlServer) DefaultSink() (string, error) {
+	return s.defaultDevice("sink")
+}
+
+// DefaultSource returns the name of the default input
+func (s *PactlServer) DefaultSource() (string, error) {
+	return s.defaultDevice("source")
+}
+
+// SetDefaultSource changes the default input
+func (s *PactlServer) SetDefaultSource(name string) error {
+	_, err := s.run("set-default-source", name)
+	return err
+}
+
+// MoveSourceOutput moves a recording stream to the named source
+func (s *PactlServer) MoveSourceOutput(index int, source string) error {
+	_, err := s.run("move-source-output", strconv.Itoa(index), source)
+	return err
+}
+
+// MoveSinkInput moves a playback stream to the named sink
+func (s *PactlServer) MoveSinkInput(index int, sink string) error {
+	_, err := s.run("move-sink-input", strconv.Itoa(index), sink)
+	return err
+}
+
+// LoadModule loads a module with key=value arguments and returns its index
+func (s *PactlServer) LoadModule(name string, args ...string) (int, error) {
+	output, err := s.run(append([]string{"load-module", name}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	index, err := strconv.Atoi(output)
+	if err != nil {
+		// The module is loaded but we can't track it; unload it rather than leak it
+		if loaded, found := s.findModule(name, args); found {
+			s.UnloadModule(loaded)
+		}
+		return 0, fmt.Errorf("failed to parse module index from pactl output %q: %v", output, err)
+	}
+	return index, nil
+}
+
+// findModule returns the most recently loaded module with the given name and arguments
+func (s *PactlServer) findModule(name string, args []string) (int, bool) {
+	rows, err := s.listShort("modules")
+	if err != nil {
+		return 0, false
+	}
+
+	found := -1
+	for _, row := range rows {
+		index, err := strconv.Atoi(field(row, 0))
+		if err != nil || field(row, 1) != name || field(row, 2) != strings.Join(args, " ") {
+			continue
+		}
+		found = max(found, index)
+	}
+	return found, found >= 0
+}
+
+// UnloadModule unloads a module by index
+func (s *PactlServer) UnloadModule(index int) error {
+	_, err := s.run("unload-module", strconv.Itoa(index))
+	return err
+}
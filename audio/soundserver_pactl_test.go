@@ -0,0 +1,127 @@
+//go:build unix
+
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePactl puts a pactl script first in PATH that answers --format=json with the
+// contents of dir/json, exiting with dir/status and printing dir/stderr when it fails
+func fakePactl(t *testing.T) string {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+if [ "$1" = "--format=json" ]; then
+	echo json >> "$dir/calls"
+	status=$(cat "$dir/status")
+	if [ "$status" != 0 ]; then
+		cat "$dir/stderr" >&2
+		exit "$status"
+	fi
+	cat "$dir/json"
+	exit 0
+fi
+echo text >> "$dir/calls"
+printf '3\tspeakers\tmodule-alsa-card.c\ts16le 2ch 44100Hz\tSUSPENDED\n'
+`
+	if err := os.WriteFile(filepath.Join(dir, "pactl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+// setPactl sets how the fake pactl answers --format=json
+func setPactl(t *testing.T, dir, status, stderr, json string) {
+	for name, content := range map[string]string{"status": status, "stderr": stderr, "json": json} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// jsonCalls returns how often the fake pactl was asked for JSON
+func jsonCalls(t *testing.T, dir string) int {
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(calls), "json\n")
+}
+
+func TestPactlJSONRetriedAfterServerError(t *testing.T) {
+	dir := fakePactl(t)
+	server := NewPactlServer()
+
+	setPactl(t, dir, "1", "Connection failure: Connection refused\n", "")
+	if sinks, err := server.ListSinks(); err != nil || len(sinks) != 1 || sinks[0].Name != "speakers" {
+		t.Fatalf("ListSinks = %v, %v, want the text listing", sinks, err)
+	}
+
+	setPactl(t, dir, "0", "", `[{"index":7,"name":"usb","state":"RUNNING"}]`)
+	if sinks, err := server.ListSinks(); err != nil || len(sinks) != 1 || sinks[0].Name != "usb" {
+		t.Fatalf("ListSinks = %v, %v, want the JSON listing once pactl works", sinks, err)
+	}
+}
+
+func TestPactlJSONCachedWhenRejected(t *testing.T) {
+	dir := fakePactl(t)
+	server := NewPactlServer()
+
+	setPactl(t, dir, "1", "pactl: unrecognized option '--format=json'\n", "")
+	for i := 0; i < 3; i++ {
+		if sinks, err := server.ListSinks(); err != nil || len(sinks) != 1 || sinks[0].Name != "speakers" {
+			t.Fatalf("ListSinks = %v, %v, want the text listing", sinks, err)
+		}
+	}
+	if calls := jsonCalls(t, dir); calls != 1 {
+		t.Errorf("--format=json tried %d times, want once", calls)
+	}
+}
+
+func TestPactlJSONCacheConcurrent(t *testing.T) {
+	dir := fakePactl(t)
+	server := NewPactlServer()
+	setPactl(t, dir, "1", "pactl: unrecognized option '--format=json'\n", "")
+
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := server.ListSinks()
+			done <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestPactlLoadModuleUnloadsUnparsedModule(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+dir=$(dirname "$0")
+case "$1" in
+load-module) echo "Module loaded" ;;
+list) printf '12\tmodule-null-sink\tsink_name=other\n13\tmodule-null-sink\tsink_name=milkshaker_capture\n' ;;
+unload-module) echo "$2" >> "$dir/unloaded" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "pactl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	_, err := NewPactlServer().LoadModule("module-null-sink", "sink_name="+captureSinkName)
+	if err == nil || !strings.Contains(err.Error(), "Module loaded") {
+		t.Errorf("LoadModule error = %v, want one showing pactl's output", err)
+	}
+	if unloaded, _ := os.ReadFile(filepath.Join(dir, "unloaded")); string(unloaded) != "13\n" {
+		t.Errorf("unloaded modules %q, want 13", unloaded)
+	}
+}
@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"time"
+)
+
+// BufferHandler receives non-interleaved float32 frames, one slice per channel, and
+// the wall-clock time at which the first frame was captured
+type BufferHandler func(buffer [][]float32, captured time.Time)
+
+// Source delivers captured, decoded or generated audio to the player
+type Source interface {
+	// Open prepares the source and registers the handler that receives its buffers
+	Open(handler BufferHandler) error
+	// Start begins delivering buffers to the handler
+	Start() error
+	// Stop pauses delivery; the source can be started again
+	Stop() error
+	// Close releases all resources held by the source
+	Close() error
+
+	// Name returns a human readable description of the source
+	Name() string
+	// SampleRate returns the rate of the delivered audio in Hz
+	SampleRate() float64
+	// FramesPerBuffer returns the number of frames per delivered buffer
+	FramesPerBuffer() int
+	// Channels returns the number of channels per buffer
+	Channels() int
+}
+
+// latencyReporter is implemented by sources that know the input latency of their device
+type latencyReporter interface {
+	InputLatency() time.Duration
+}
+
+// errorReporter is implemented by sources that can stop delivering audio because of an error
+type errorReporter interface {
+	Err() error
+}
+
+// finishReporter is implemented by sources that end on their own, like files played without looping
+type finishReporter interface {
+	Finished() bool
+}
+
+// runPaced calls produce once per buffer duration until stop is closed or produce returns
+// false, scheduling against absolute time so real-time playback does not drift
+func runPaced(stop <-chan struct{}, bufferDuration time.Duration, produce func() bool) {
+	next := time.Now()
+
+	for {
+		if !produce() {
+			return
+		}
+
+		next = next.Add(bufferDuration)
+		wait := time.Until(next)
+		if wait < -time.Second {
+			// Fell far behind (e.g. system was suspended); resync instead of bursting
+			next = time.Now()
+			wait = 0
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSource plays a WAV or FLAC file in real time, paced by the wall clock
+type FileSource struct {
+	path            string
+	loop            bool
+	decoder         audioDecoder
+	handler         BufferHandler
+	sampleRate      float64
+	channels        int
+	framesPerBuffer int
+
+	mutex    sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	finished bool
+	err      error
+}
+
+// NewFileSource creates a source for an audio file, optionally looping it forever
+func NewFileSource(path string, loop bool) *FileSource {
+	return &FileSource{
+		path:            path,
+		loop:            loop,
+		framesPerBuffer: defaultFramesPerBuffer,
+	}
+}
+
+// Open decodes the file headers
+func (s *FileSource) Open(handler BufferHandler) error {
+	if s.decoder != nil {
+		s.decoder.close()
+	}
+
+	decoder, err := openDecoder(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.decoder = decoder
+	s.handler = handler
+	s.sampleRate = decoder.sampleRate()
+	s.channels = decoder.channels()
+	s.finished = false
+	s.err = nil
+	return nil
+}
+
+// Start begins (or resumes) playback
+func (s *FileSource) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.decoder == nil {
+		return fmt.Errorf("file source not opened")
+	}
+	if s.stop != nil {
+		return nil
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.play(s.stop, s.done)
+	return nil
+}
+
+// Stop pauses playback at the current position
+func (s *FileSource) Stop() error {
+	s.mutex.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
+
+// Close stops playback and closes the file
+func (s *FileSource) Close() error {
+	s.Stop()
+	if s.decoder == nil {
+		return nil
+	}
+	err := s.decoder.close()
+	s.decoder = nil
+	return err
+}
+
+// play delivers one buffer per buffer duration until stopped or the file ends
+func (s *FileSource) play(stop, done chan struct{}) {
+	defer close(done)
+
+	buffer := make([][]float32, s.channels)
+	for ch := range buffer {
+		buffer[ch] = make([]float32, s.framesPerBuffer)
+	}
+
+	bufferDuration := time.Duration(float64(s.framesPerBuffer) / s.sampleRate * float64(time.Second))
+	runPaced(stop, bufferDuration, func() bool {
+		if s.fill(buffer) > 0 {
+			s.handler(buffer, time.Now())
+		}
+		return !s.Finished()
+	})
+}
+
+// fill reads the next buffer, looping or padding with silence at the end of the file, and
+// returns the number of frames read. A decode error ends playback like the end of the file
+// and is kept for Err
+func (s *FileSource) fill(buffer [][]float32) int {
+	filled := 0
+	for filled < len(buffer[0]) && !s.finished {
+		view := make([][]float32, len(buffer))
+		for ch := range buffer {
+			view[ch] = buffer[ch][filled:]
+		}
+
+		n, err := s.decoder.read(view)
+		filled += n
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			s.fail(err)
+			break
+		}
+
+		if !s.loop {
+			s.markFinished()
+			break
+		}
+
+		// Rewind by reopening the decoder
+		decoder, err := openDecoder(s.path)
+		if err != nil {
+			s.fail(err)
+			break
+		}
+		s.decoder.close()
+		s.decoder = decoder
+	}
+
+	for ch := range buffer {
+		for i := filled; i < len(buffer[ch]); i++ {
+			buffer[ch][i] = 0
+		}
+	}
+	return filled
+}
+
+// markFinished records that playback reached the end of the file
+func (s *FileSource) markFinished() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finished = true
+}
+
+// fail stops playback because the file could not be decoded
+func (s *FileSource) fail(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finished = true
+	s.err = fmt.Errorf("failed to decode %s: %w", filepath.Base(s.path), err)
+}
+
+// Finished returns true once a non-looping file has played to the end or failed to decode
+func (s *FileSource) Finished() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.finished
+}
+
+// Err returns the error that ended playback early, if any
+func (s *FileSource) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// Name returns the file name
+func (s *FileSource) Name() string {
+	return filepath.Base(s.path)
+}
+
+// SampleRate returns the sample rate of the file
+func (s *FileSource) SampleRate() float64 {
+	return s.sampleRate
+}
+
+// FramesPerBuffer returns the number of frames delivered per buffer
+func (s *FileSource) FramesPerBuffer() int {
+	return s.framesPerBuffer
+}
+
+// Channels returns the channel count of the file
+func (s *FileSource) Channels() int {
+	return s.channels
+}
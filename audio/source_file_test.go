@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFileSourceStopsOnCorruptFile(t *testing.T) {
+	source := NewFileSource(filepath.Join("testdata", "flac", "corrupt_partitions.flac"), true)
+	var buffers atomic.Int64
+	if err := source.Open(func(buffer [][]float32, captured time.Time) {
+		buffers.Add(1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	source.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for !source.Finished() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	source.Close()
+	if !source.Finished() || !errors.Is(source.Err(), ErrCorrupt) {
+		t.Errorf("finished %v with error %v, want finished with ErrCorrupt", source.Finished(), source.Err())
+	}
+	// The first frame is already corrupt, so there is nothing to deliver, not even silence
+	if got := buffers.Load(); got != 0 {
+		t.Errorf("delivered %d buffers, want 0", got)
+	}
+}
+
+func TestFileSourceStopsAtEnd(t *testing.T) {
+	// fixed.flac holds 2892 frames: two full buffers and a partial one
+	source := NewFileSource(filepath.Join("testdata", "flac", "fixed.flac"), false)
+	var buffers atomic.Int64
+	if err := source.Open(func(buffer [][]float32, captured time.Time) {
+		buffers.Add(1)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	player := NewPlayerWithSource(source)
+	source.Start()
+	defer source.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for !player.IsFinished() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !player.IsFinished() || source.Err() != nil {
+		t.Fatalf("finished %v with error %v, want finished without error", player.IsFinished(), source.Err())
+	}
+
+	// No silence is delivered after the end
+	time.Sleep(100 * time.Millisecond)
+	if got := buffers.Load(); got != 3 {
+		t.Errorf("delivered %d buffers, want 3", got)
+	}
+}
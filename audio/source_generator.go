@@ -0,0 +1,232 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Signal names a synthetic test signal
+type Signal string
+
+// Built-in generator signals
+const (
+	SignalSweep   Signal = "sweep"
+	SignalPink    Signal = "pink"
+	SignalKick    Signal = "kick"
+	SignalSilence Signal = "silence"
+)
+
+// Signals lists every signal the generator can produce
+var Signals = []Signal{SignalSweep, SignalPink, SignalKick, SignalSilence}
+
+const (
+	// Logarithmic sweep range and duration
+	sweepLow      = 20.0
+	sweepHigh     = 20000.0
+	sweepDuration = 10.0
+
+	// Kick drum pitch drop and decay
+	kickStartHz  = 150.0
+	kickEndHz    = 45.0
+	kickPitchTau = 0.03
+	kickAmpTau   = 0.08
+	kickLength   = 0.5
+
+	generatorLevel = 0.5
+	defaultBPM     = 120.0
+)
+
+// GeneratorSource synthesizes test signals in real time, paced by the wall clock.
+// Output is deterministic, so runs are reproducible without any audio hardware
+type GeneratorSource struct {
+	signal          Signal
+	bpm             float64
+	sampleRate      float64
+	channels        int
+	framesPerBuffer int
+	handler         BufferHandler
+
+	position int64 // Samples generated so far
+	phase    float64
+	pink     [7]float64
+	rng      *rand.Rand
+
+	mutex sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewGeneratorSource creates a generator for the given signal; bpm sets the kick rate
+func NewGeneratorSource(signal Signal, bpm float64) (*GeneratorSource, error) {
+	known := false
+	for _, s := range Signals {
+		if s == signal {
+			known = true
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown signal %q (use sweep, pink, kick or silence)", signal)
+	}
+	if bpm <= 0 {
+		bpm = defaultBPM
+	}
+
+	return &GeneratorSource{
+		signal:          signal,
+		bpm:             bpm,
+		sampleRate:      defaultSampleRate,
+		channels:        2,
+		framesPerBuffer: defaultFramesPerBuffer,
+		rng:             rand.New(rand.NewSource(1)),
+	}, nil
+}
+
+// Open registers the handler; there is nothing else to prepare
+func (g *GeneratorSource) Open(handler BufferHandler) error {
+	g.handler = handler
+	return nil
+}
+
+// Start begins generating buffers in the background
+func (g *GeneratorSource) Start() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.handler == nil {
+		return fmt.Errorf("generator source not opened")
+	}
+	if g.stop != nil {
+		return nil
+	}
+
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go g.play(g.stop, g.done)
+	return nil
+}
+
+// Stop pauses generation; the signal resumes where it left off
+func (g *GeneratorSource) Stop() error {
+	g.mutex.Lock()
+	stop, done := g.stop, g.done
+	g.stop, g.done = nil, nil
+	g.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return nil
+}
+
+// Close stops generation
+func (g *GeneratorSource) Close() error {
+	return g.Stop()
+}
+
+// play delivers one buffer per buffer duration until stopped
+func (g *GeneratorSource) play(stop, done chan struct{}) {
+	defer close(done)
+
+	buffer := make([][]float32, g.channels)
+	for ch := range buffer {
+		buffer[ch] = make([]float32, g.framesPerBuffer)
+	}
+
+	bufferDuration := time.Duration(float64(g.framesPerBuffer) / g.sampleRate * float64(time.Second))
+	runPaced(stop, bufferDuration, func() bool {
+		g.Generate(buffer)
+		g.handler(buffer, time.Now())
+		return true
+	})
+}
+
+// Generate fills buffer with the next samples of the signal, the same on every channel.
+// It must not be called while the source is started
+func (g *GeneratorSource) Generate(buffer [][]float32) {
+	if len(buffer) == 0 {
+		return
+	}
+
+	for i := range buffer[0] {
+		sample := float32(g.next())
+		for ch := range buffer {
+			buffer[ch][i] = sample
+		}
+	}
+}
+
+// next returns the next sample of the signal
+func (g *GeneratorSource) next() float64 {
+	t := float64(g.position) / g.sampleRate
+	g.position++
+
+	switch g.signal {
+	case SignalSweep:
+		// Exponential sweep so every octave gets the same time
+		progress := math.Mod(t, sweepDuration) / sweepDuration
+		freq := sweepLow * math.Pow(sweepHigh/sweepLow, progress)
+		g.phase = math.Mod(g.phase+2*math.Pi*freq/g.sampleRate, 2*math.Pi)
+		return generatorLevel * math.Sin(g.phase)
+
+	case SignalPink:
+		return generatorLevel * g.nextPink()
+
+	case SignalKick:
+		beatLength := 60 / g.bpm
+		since := math.Mod(t, beatLength)
+		if since > kickLength {
+			g.phase = 0
+			return 0
+		}
+		freq := kickEndHz + (kickStartHz-kickEndHz)*math.Exp(-since/kickPitchTau)
+		g.phase = math.Mod(g.phase+2*math.Pi*freq/g.sampleRate, 2*math.Pi)
+		return 0.9 * math.Exp(-since/kickAmpTau) * math.Sin(g.phase)
+	}
+
+	return 0
+}
+
+// nextPink filters white noise to a -3 dB/octave slope (Paul Kellet's refined method)
+func (g *GeneratorSource) nextPink() float64 {
+	white := g.rng.Float64()*2 - 1
+	b := &g.pink
+
+	b[0] = 0.99886*b[0] + white*0.0555179
+	b[1] = 0.99332*b[1] + white*0.0750759
+	b[2] = 0.96900*b[2] + white*0.1538520
+	b[3] = 0.86650*b[3] + white*0.3104856
+	b[4] = 0.55000*b[4] + white*0.5329522
+	b[5] = -0.7616*b[5] - white*0.0168980
+	pink := b[0] + b[1] + b[2] + b[3] + b[4] + b[5] + b[6] + white*0.5362
+	b[6] = white * 0.115926
+
+	// Normalize to roughly ±1
+	return math.Max(-1, math.Min(1, pink*0.11))
+}
+
+// Name describes the generated signal
+func (g *GeneratorSource) Name() string {
+	if g.signal == SignalKick {
+		return fmt.Sprintf("Generator: kick @ %.0f BPM", g.bpm)
+	}
+	return fmt.Sprintf("Generator: %s", g.signal)
+}
+
+// SampleRate returns the generated sample rate
+func (g *GeneratorSource) SampleRate() float64 {
+	return g.sampleRate
+}
+
+// FramesPerBuffer returns the number of frames delivered per buffer
+func (g *GeneratorSource) FramesPerBuffer() int {
+	return g.framesPerBuffer
+}
+
+// Channels returns the number of generated channels
+func (g *GeneratorSource) Channels() int {
+	return g.channels
+}
@@ -0,0 +1,297 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// PCMFormat names the sample encoding of a raw PCM stream
+type PCMFormat string
+
+// Supported raw PCM encodings
+const (
+	PCMS16LE PCMFormat = "s16le"
+	PCMF32LE PCMFormat = "f32le"
+)
+
+// PCMSource reads raw interleaved PCM from stdin, a file or a named pipe (FIFO)
+type PCMSource struct {
+	path            string // "-" reads stdin
+	format          PCMFormat
+	sampleRate      float64
+	channels        int
+	framesPerBuffer int
+	decode          func([]byte) float32
+	bytesPerSample  int
+	handler         BufferHandler
+
+	mutex    sync.Mutex
+	reader   io.ReadCloser
+	stop     chan struct{}
+	done     chan struct{}
+	finished bool
+
+	// Reads from stdin can't be interrupted and its buffered bytes must not be split
+	// between readers, so one goroutine reads it for the whole process and Start and
+	// Stop only switch delivery on and off
+	stdinReading bool
+	delivering   bool
+}
+
+// NewPCMSource creates a raw PCM source; path "-" reads from stdin
+func NewPCMSource(path string, format PCMFormat, sampleRate float64, channels int) (*PCMSource, error) {
+	var decode func([]byte) float32
+	var bytesPerSample int
+	var err error
+
+	switch format {
+	case PCMS16LE:
+		decode, err = sampleDecoder(false, 16)
+		bytesPerSample = 2
+	case PCMF32LE:
+		decode, err = sampleDecoder(true, 32)
+		bytesPerSample = 4
+	default:
+		return nil, fmt.Errorf("unsupported PCM format %q (use %s or %s)", format, PCMS16LE, PCMF32LE)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if sampleRate <= 0 || channels < 1 {
+		return nil, fmt.Errorf("invalid PCM stream layout: %.0f Hz, %d channels", sampleRate, channels)
+	}
+
+	return &PCMSource{
+		path:            path,
+		format:          format,
+		sampleRate:      sampleRate,
+		channels:        channels,
+		framesPerBuffer: defaultFramesPerBuffer,
+		decode:          decode,
+		bytesPerSample:  bytesPerSample,
+	}, nil
+}
+
+// Open checks that the input exists; the input itself is opened once reading starts
+func (s *PCMSource) Open(handler BufferHandler) error {
+	s.handler = handler
+
+	if s.path == "-" {
+		return nil
+	}
+	if _, err := os.Stat(s.path); err != nil {
+		return fmt.Errorf("failed to open PCM input: %v", err)
+	}
+	return nil
+}
+
+// Start begins reading PCM in the background
+func (s *PCMSource) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.path == "-" {
+		s.delivering = true
+		if !s.stdinReading {
+			s.stdinReading = true
+			go s.readStdin()
+		}
+		return nil
+	}
+
+	if s.stop != nil {
+		return nil
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.read(s.stop, s.done)
+	return nil
+}
+
+// Stop stops reading and returns once no more buffers will be delivered; a blocked
+// read is interrupted by closing the input
+func (s *PCMSource) Stop() error {
+	s.mutex.Lock()
+	s.delivering = false
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	if stop != nil {
+		// Close before the input so the reader can't open it again
+		close(stop)
+	}
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	s.mutex.Unlock()
+
+	if done != nil {
+		<-done
+	}
+	return nil
+}
+
+// Close stops reading
+func (s *PCMSource) Close() error {
+	return s.Stop()
+}
+
+// openReader opens the configured file or FIFO. A FIFO is opened for writing too, so
+// opening never blocks until a writer appears and reads never hit EOF between writers
+func (s *PCMSource) openReader() (io.ReadCloser, error) {
+	if info, err := os.Stat(s.path); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return os.OpenFile(s.path, os.O_RDWR, 0)
+	}
+	return os.Open(s.path)
+}
+
+// read delivers buffers from a file or FIFO until it ends or stop is closed
+func (s *PCMSource) read(stop, done chan struct{}) {
+	defer close(done)
+
+	reader, err := s.openReader()
+	if err != nil {
+		s.markFinished()
+		return
+	}
+
+	s.mutex.Lock()
+	select {
+	case <-stop:
+		s.mutex.Unlock()
+		reader.Close()
+		return
+	default:
+	}
+	s.reader = reader
+	s.mutex.Unlock()
+
+	s.decodeFrom(reader, isWriterPaced(reader), stop, func(buffer [][]float32) bool {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		s.handler(buffer, time.Now())
+		return true
+	})
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	select {
+	case <-stop:
+		// Stop closed the input
+	default:
+		reader.Close()
+		s.reader = nil
+		s.finished = true
+	}
+}
+
+// readStdin delivers buffers from stdin while started, until stdin ends
+func (s *PCMSource) readStdin() {
+	s.decodeFrom(os.Stdin, isWriterPaced(os.Stdin), nil, func(buffer [][]float32) bool {
+		// Holding the mutex lets Stop wait for a buffer being delivered
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.delivering {
+			s.handler(buffer, time.Now())
+		}
+		return true
+	})
+	s.markFinished()
+}
+
+// isWriterPaced returns whether reads from input block until a writer supplies data, as
+// for FIFOs, pipes, sockets and terminals; anything else, like a regular file, can be
+// read at any speed and has to be paced by the wall clock
+func isWriterPaced(input io.Reader) bool {
+	file, ok := input.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return true
+	}
+	return info.Mode()&(os.ModeNamedPipe|os.ModeSocket|os.ModeCharDevice) != 0
+}
+
+// decodeFrom converts interleaved PCM into per-channel buffers and passes them to deliver
+// until the input fails, deliver returns false or stop is closed. Input the writer
+// doesn't pace is delivered in real time
+func (s *PCMSource) decodeFrom(reader io.Reader, writerPaced bool, stop <-chan struct{}, deliver func(buffer [][]float32) bool) {
+	frameBytes := s.bytesPerSample * s.channels
+	raw := make([]byte, s.framesPerBuffer*frameBytes)
+
+	buffer := make([][]float32, s.channels)
+	for ch := range buffer {
+		buffer[ch] = make([]float32, s.framesPerBuffer)
+	}
+
+	buffered := bufio.NewReaderSize(reader, len(raw)*4)
+	next := func() bool {
+		if _, err := io.ReadFull(buffered, raw); err != nil {
+			return false
+		}
+
+		for i := 0; i < s.framesPerBuffer; i++ {
+			for ch := 0; ch < s.channels; ch++ {
+				buffer[ch][i] = s.decode(raw[i*frameBytes+ch*s.bytesPerSample:])
+			}
+		}
+		return deliver(buffer)
+	}
+
+	if writerPaced {
+		// The writer sets the pace, so simply block on full buffers
+		for next() {
+		}
+		return
+	}
+	bufferDuration := time.Duration(float64(s.framesPerBuffer) / s.sampleRate * float64(time.Second))
+	runPaced(stop, bufferDuration, next)
+}
+
+// markFinished records that the input reached its end
+func (s *PCMSource) markFinished() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finished = true
+}
+
+// Finished returns true once stdin or a regular file has been read to the end
+func (s *PCMSource) Finished() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.finished
+}
+
+// Name describes the input
+func (s *PCMSource) Name() string {
+	input := s.path
+	if input == "-" {
+		input = "stdin"
+	}
+	return fmt.Sprintf("PCM %s (%s, %.0f Hz, %d ch)", input, s.format, s.sampleRate, s.channels)
+}
+
+// SampleRate returns the configured sample rate
+func (s *PCMSource) SampleRate() float64 {
+	return s.sampleRate
+}
+
+// FramesPerBuffer returns the number of frames delivered per buffer
+func (s *PCMSource) FramesPerBuffer() int {
+	return s.framesPerBuffer
+}
+
+// Channels returns the configured channel count
+func (s *PCMSource) Channels() int {
+	return s.channels
+}
@@ -0,0 +1,139 @@
+//go:build unix
+
+package audio
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// pcmFrames encodes frames of stereo s16le with the given left and right values
+func pcmFrames(frames int, left, right int16) []byte {
+	raw := make([]byte, frames*4)
+	for i := 0; i < frames; i++ {
+		binary.LittleEndian.PutUint16(raw[i*4:], uint16(left))
+		binary.LittleEndian.PutUint16(raw[i*4+2:], uint16(right))
+	}
+	return raw
+}
+
+func TestPCMSourceFIFORestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pcm.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	source, err := NewPCMSource(path, PCMS16LE, 44100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffers, misaligned atomic.Int64
+	source.Open(func(buffer [][]float32, captured time.Time) {
+		buffers.Add(1)
+		if buffer[0][0] <= 0 || buffer[1][0] >= 0 {
+			misaligned.Add(1)
+		}
+	})
+
+	// Stop must not hang while nobody writes to the FIFO
+	source.Start()
+	stopped := make(chan struct{})
+	go func() {
+		source.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked without a writer")
+	}
+
+	writer, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	source.Start()
+	writer.Write(pcmFrames(defaultFramesPerBuffer*3, 1000, -1000))
+	deadline := time.Now().Add(time.Second)
+	for buffers.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	source.Stop()
+
+	delivered := buffers.Load()
+	if delivered != 3 {
+		t.Fatalf("delivered %d buffers, want 3", delivered)
+	}
+	writer.Write(pcmFrames(defaultFramesPerBuffer, 1000, -1000))
+	time.Sleep(50 * time.Millisecond)
+	if buffers.Load() != delivered {
+		t.Error("buffers delivered after Stop returned")
+	}
+	if misaligned.Load() != 0 {
+		t.Errorf("%d misaligned buffers", misaligned.Load())
+	}
+	source.Close()
+}
+
+func TestPCMSourceFileFinishes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pcm.raw")
+	if err := os.WriteFile(path, pcmFrames(defaultFramesPerBuffer*2, 1000, -1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewPCMSource(path, PCMS16LE, 44100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffers atomic.Int64
+	source.Open(func(buffer [][]float32, captured time.Time) {
+		buffers.Add(1)
+	})
+	source.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for !source.Finished() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	source.Close()
+	if !source.Finished() || buffers.Load() != 2 {
+		t.Errorf("finished %v after %d buffers, want true after 2", source.Finished(), buffers.Load())
+	}
+}
+
+func TestPCMSourceFilePlaysInRealTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pcm.raw")
+	if err := os.WriteFile(path, pcmFrames(defaultFramesPerBuffer*8, 1000, -1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewPCMSource(path, PCMS16LE, defaultSampleRate, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first, last atomic.Int64
+	source.Open(func(buffer [][]float32, captured time.Time) {
+		first.CompareAndSwap(0, captured.UnixNano())
+		last.Store(captured.UnixNano())
+	})
+	source.Start()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !source.Finished() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	source.Close()
+
+	// Eight buffers span seven buffer durations from the first delivery to the last
+	bufferDuration := time.Second * defaultFramesPerBuffer / defaultSampleRate
+	if elapsed := time.Duration(last.Load() - first.Load()); elapsed < 7*bufferDuration-10*time.Millisecond {
+		t.Errorf("delivered 8 buffers in %v, want about %v", elapsed, 7*bufferDuration)
+	}
+}
@@ -0,0 +1,219 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// StreamConfig requests a capture format; zero fields are negotiated automatically
+type StreamConfig struct {
+	SampleRate      float64 // Hz; 0 tries the device default, then 44100
+	FramesPerBuffer int     // 0 uses 1024; smaller buffers lower latency
+	Channels        int     // 0 uses stereo, or mono on single-channel devices
+}
+
+// PortAudioSource captures audio from a PortAudio input device
+type PortAudioSource struct {
+	device          *portaudio.DeviceInfo
+	config          StreamConfig
+	stream          *portaudio.Stream
+	sampleRate      float64
+	framesPerBuffer int
+	channels        int
+	inputLatency    time.Duration // Reported by the host once the stream is open
+}
+
+// Stream clock readings further behind than this are treated as bogus
+const maxCaptureAge = time.Second
+
+// NewPortAudioSource creates a source for the given input device with the default format
+func NewPortAudioSource(device *portaudio.DeviceInfo) *PortAudioSource {
+	return NewPortAudioSourceWithConfig(device, StreamConfig{})
+}
+
+// NewPortAudioSourceWithConfig creates a source for the given input device, negotiating the requested format on Open
+func NewPortAudioSourceWithConfig(device *portaudio.DeviceInfo, config StreamConfig) *PortAudioSource {
+	return &PortAudioSource{
+		device:          device,
+		config:          config,
+		sampleRate:      defaultSampleRate,
+		framesPerBuffer: defaultFramesPerBuffer,
+		channels:        1,
+	}
+}
+
+// NegotiateStreamConfig returns the first format the device supports, trying the requested
+// sample rate, then the device default, then 44100 Hz, and the requested channels before stereo and mono
+func NegotiateStreamConfig(device *portaudio.DeviceInfo, config StreamConfig) (StreamConfig, error) {
+	if device.MaxInputChannels < 1 {
+		return StreamConfig{}, fmt.Errorf("%s has no input channels", device.Name)
+	}
+
+	framesPerBuffer := config.FramesPerBuffer
+	if framesPerBuffer <= 0 {
+		framesPerBuffer = defaultFramesPerBuffer
+	}
+
+	var rates []float64
+	for _, rate := range []float64{config.SampleRate, device.DefaultSampleRate, defaultSampleRate} {
+		if rate > 0 && !containsRate(rates, rate) {
+			rates = append(rates, rate)
+		}
+	}
+
+	var channelCounts []int
+	for _, channels := range []int{config.Channels, 2, 1} {
+		if channels > device.MaxInputChannels {
+			channels = device.MaxInputChannels
+		}
+		if channels > 0 && !containsChannels(channelCounts, channels) {
+			channelCounts = append(channelCounts, channels)
+		}
+	}
+
+	var lastErr error
+	for _, rate := range rates {
+		for _, channels := range channelCounts {
+			params := streamParameters(device, rate, framesPerBuffer, channels)
+			if lastErr = portaudio.IsFormatSupported(params, func([][]float32) {}); lastErr == nil {
+				return StreamConfig{SampleRate: rate, FramesPerBuffer: framesPerBuffer, Channels: channels}, nil
+			}
+		}
+	}
+	return StreamConfig{}, fmt.Errorf("no supported capture format on %s: %v", device.Name, lastErr)
+}
+
+// streamParameters builds low-latency input parameters for a device
+func streamParameters(device *portaudio.DeviceInfo, sampleRate float64, framesPerBuffer, channels int) portaudio.StreamParameters {
+	return portaudio.StreamParameters{
+		Input: portaudio.StreamDeviceParameters{
+			Device:   device,
+			Channels: channels,
+			Latency:  device.DefaultLowInputLatency,
+		},
+		SampleRate:      sampleRate,
+		FramesPerBuffer: framesPerBuffer,
+	}
+}
+
+func containsRate(rates []float64, rate float64) bool {
+	for _, r := range rates {
+		if r == rate {
+			return true
+		}
+	}
+	return false
+}
+
+func containsChannels(counts []int, channels int) bool {
+	for _, c := range counts {
+		if c == channels {
+			return true
+		}
+	}
+	return false
+}
+
+// Open negotiates the capture format and opens the PortAudio input stream
+func (s *PortAudioSource) Open(handler BufferHandler) error {
+	if s.stream != nil {
+		s.stream.Close()
+	}
+
+	negotiated, err := NegotiateStreamConfig(s.device, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to open audio stream: %v", err)
+	}
+
+	callback := func(inputBuffer [][]float32, timeInfo portaudio.StreamCallbackTimeInfo) {
+		handler(inputBuffer, s.captureTime(time.Now(), timeInfo))
+	}
+
+	params := streamParameters(s.device, negotiated.SampleRate, negotiated.FramesPerBuffer, negotiated.Channels)
+	s.stream, err = portaudio.OpenStream(params, callback)
+	if err != nil {
+		return fmt.Errorf("failed to open audio stream: %v", err)
+	}
+
+	s.sampleRate = negotiated.SampleRate
+	s.framesPerBuffer = negotiated.FramesPerBuffer
+	s.channels = negotiated.Channels
+
+	// The host may still adjust the rate slightly
+	if info := s.stream.Info(); info != nil {
+		if info.SampleRate > 0 {
+			s.sampleRate = info.SampleRate
+		}
+		s.inputLatency = info.InputLatency
+	}
+
+	return nil
+}
+
+// captureTime converts the stream time at which a buffer was captured to wall-clock time.
+// Hosts without a usable stream clock (e.g. some ALSA plugins report 0) fall back to the
+// reported input latency
+func (s *PortAudioSource) captureTime(now time.Time, timeInfo portaudio.StreamCallbackTimeInfo) time.Time {
+	age := timeInfo.CurrentTime - timeInfo.InputBufferAdcTime
+	if timeInfo.InputBufferAdcTime <= 0 || age < 0 || age > maxCaptureAge {
+		age = s.inputLatency
+	}
+	return now.Add(-age)
+}
+
+// InputLatency returns the input latency reported by the host for the open stream
+func (s *PortAudioSource) InputLatency() time.Duration {
+	return s.inputLatency
+}
+
+// Start starts the PortAudio stream
+func (s *PortAudioSource) Start() error {
+	if s.stream == nil {
+		return fmt.Errorf("audio stream not initialized")
+	}
+
+	if err := s.stream.Start(); err != nil {
+		return fmt.Errorf("failed to start audio stream: %v", err)
+	}
+	return nil
+}
+
+// Stop stops the PortAudio stream
+func (s *PortAudioSource) Stop() error {
+	if s.stream == nil {
+		return nil
+	}
+	return s.stream.Stop()
+}
+
+// Close closes the PortAudio stream
+func (s *PortAudioSource) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	err := s.stream.Close()
+	s.stream = nil
+	return err
+}
+
+// Name returns the device name
+func (s *PortAudioSource) Name() string {
+	return s.device.Name
+}
+
+// SampleRate returns the stream sample rate
+func (s *PortAudioSource) SampleRate() float64 {
+	return s.sampleRate
+}
+
+// FramesPerBuffer returns the stream buffer size
+func (s *PortAudioSource) FramesPerBuffer() int {
+	return s.framesPerBuffer
+}
+
+// Channels returns the number of captured channels
+func (s *PortAudioSource) Channels() int {
+	return s.channels
+}
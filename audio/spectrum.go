@@ -0,0 +1,222 @@
+package audio
+
+import (
+	"math"
+)
+
+// SpectrumAnalyzer computes a windowed FFT magnitude spectrum and groups it into display bins
+type SpectrumAnalyzer struct {
+	sampleRate float64
+	fftSize    int
+
+	// Sliding analysis window so any buffer size can feed a fixed FFT size
+	history []float64
+	window  []float64
+	winSum  float64
+	real    []float64
+	imag    []float64
+
+	// Linear magnitudes for every FFT bin up to Nyquist
+	magnitudes []float64
+
+	// Grouped output bins
+	numBins  int
+	logScale bool
+	minFreq  float64
+	maxFreq  float64
+	edges    []int
+	bins     []float64
+}
+
+// NewSpectrumAnalyzer creates an analyzer for the given sample rate, FFT size and output bin count
+func NewSpectrumAnalyzer(sampleRate float64, fftSize, numBins int) *SpectrumAnalyzer {
+	// FFT size must be a power of two
+	size := 2
+	for size < fftSize {
+		size *= 2
+	}
+
+	a := &SpectrumAnalyzer{
+		sampleRate: sampleRate,
+		fftSize:    size,
+		history:    make([]float64, size),
+		window:     make([]float64, size),
+		real:       make([]float64, size),
+		imag:       make([]float64, size),
+		magnitudes: make([]float64, size/2),
+		logScale:   true,
+		minFreq:    30,
+		maxFreq:    16000,
+	}
+
+	// Hann window
+	for i := range a.window {
+		a.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+		a.winSum += a.window[i]
+	}
+
+	a.SetBinCount(numBins)
+	return a
+}
+
+// SetBinCount changes the number of grouped output bins
+func (a *SpectrumAnalyzer) SetBinCount(numBins int) {
+	if numBins < 1 {
+		numBins = 1
+	}
+	if numBins > len(a.magnitudes) {
+		numBins = len(a.magnitudes)
+	}
+	a.numBins = numBins
+	a.bins = make([]float64, numBins)
+	a.computeEdges()
+}
+
+// SetLogScale switches between logarithmic and linear frequency grouping
+func (a *SpectrumAnalyzer) SetLogScale(enabled bool) {
+	a.logScale = enabled
+	a.computeEdges()
+}
+
+// SetFrequencyRange limits the grouped bins to the given frequency range
+func (a *SpectrumAnalyzer) SetFrequencyRange(minFreq, maxFreq float64) {
+	if minFreq > 0 && maxFreq > minFreq {
+		a.minFreq = minFreq
+		a.maxFreq = maxFreq
+		a.computeEdges()
+	}
+}
+
+// computeEdges precomputes which FFT bins feed each output bin
+func (a *SpectrumAnalyzer) computeEdges() {
+	nyquist := a.sampleRate / 2
+	maxFreq := math.Min(a.maxFreq, nyquist)
+	minFreq := math.Min(a.minFreq, maxFreq/2)
+
+	a.edges = make([]int, a.numBins+1)
+	for i := 0; i <= a.numBins; i++ {
+		t := float64(i) / float64(a.numBins)
+
+		var freq float64
+		if a.logScale {
+			freq = minFreq * math.Pow(maxFreq/minFreq, t)
+		} else {
+			freq = minFreq + (maxFreq-minFreq)*t
+		}
+
+		a.edges[i] = a.FrequencyToBin(freq)
+	}
+}
+
+// FrequencyToBin returns the FFT bin closest to the given frequency
+func (a *SpectrumAnalyzer) FrequencyToBin(freq float64) int {
+	bin := int(math.Round(freq / a.BinHz()))
+	if bin < 0 {
+		bin = 0
+	}
+	if bin >= len(a.magnitudes) {
+		bin = len(a.magnitudes) - 1
+	}
+	return bin
+}
+
+// BinHz returns the frequency width of a single FFT bin
+func (a *SpectrumAnalyzer) BinHz() float64 {
+	return a.sampleRate / float64(a.fftSize)
+}
+
+// SampleRate returns the sample rate the analyzer was created for
+func (a *SpectrumAnalyzer) SampleRate() float64 {
+	return a.sampleRate
+}
+
+// Process feeds new mono samples into the analyzer and recomputes the spectrum
+func (a *SpectrumAnalyzer) Process(samples []float64) {
+	// Slide new samples into the analysis window
+	if len(samples) >= a.fftSize {
+		copy(a.history, samples[len(samples)-a.fftSize:])
+	} else {
+		copy(a.history, a.history[len(samples):])
+		copy(a.history[a.fftSize-len(samples):], samples)
+	}
+
+	for i := range a.history {
+		a.real[i] = a.history[i] * a.window[i]
+		a.imag[i] = 0
+	}
+
+	fft(a.real, a.imag)
+
+	// Normalize so a full-scale sine reads roughly 1.0
+	scale := 2.0 / a.winSum
+	for i := range a.magnitudes {
+		a.magnitudes[i] = math.Hypot(a.real[i], a.imag[i]) * scale
+	}
+
+	// Group into output bins, taking the strongest FFT bin in each range
+	for i := 0; i < a.numBins; i++ {
+		lo, hi := a.edges[i], a.edges[i+1]
+		if hi <= lo {
+			hi = lo + 1
+		}
+
+		maxMag := 0.0
+		for b := lo; b < hi && b < len(a.magnitudes); b++ {
+			if a.magnitudes[b] > maxMag {
+				maxMag = a.magnitudes[b]
+			}
+		}
+		a.bins[i] = maxMag
+	}
+}
+
+// Magnitudes returns the linear magnitude of every FFT bin (not a copy)
+func (a *SpectrumAnalyzer) Magnitudes() []float64 {
+	return a.magnitudes
+}
+
+// Bins returns the grouped output bins (not a copy)
+func (a *SpectrumAnalyzer) Bins() []float64 {
+	return a.bins
+}
+
+// fft performs an in-place iterative radix-2 FFT
+func fft(real, imag []float64) {
+	n := len(real)
+
+	// Bit-reversal permutation
+	j := 0
+	for i := 1; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			real[i], real[j] = real[j], real[i]
+			imag[i], imag[j] = imag[j], imag[i]
+		}
+	}
+
+	// Butterflies
+	for size := 2; size <= n; size <<= 1 {
+		half := size >> 1
+		step := -2 * math.Pi / float64(size)
+		for start := 0; start < n; start += size {
+			for k := 0; k < half; k++ {
+				wr := math.Cos(step * float64(k))
+				wi := math.Sin(step * float64(k))
+
+				a := start + k
+				b := a + half
+				tr := wr*real[b] - wi*imag[b]
+				ti := wr*imag[b] + wi*real[b]
+
+				real[b] = real[a] - tr
+				imag[b] = imag[a] - ti
+				real[a] += tr
+				imag[a] += ti
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestFFTMatchesDFT(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	real := make([]float64, 64)
+	imag := make([]float64, 64)
+	input := make([]complex128, 64)
+	for i := range real {
+		real[i] = random.Float64()*2 - 1
+		imag[i] = random.Float64()*2 - 1
+		input[i] = complex(real[i], imag[i])
+	}
+
+	fft(real, imag)
+
+	for k := range input {
+		want := complex(0, 0)
+		for n, x := range input {
+			want += x * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/float64(len(input))))
+		}
+		if got := complex(real[k], imag[k]); cmplx.Abs(got-want) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestSpectrumSinePeak(t *testing.T) {
+	tests := []struct {
+		name      string
+		bin       int
+		amplitude float64
+	}{
+		{"full scale", 47, 1.0},
+		{"half scale", 20, 0.5},
+		{"high frequency", 400, 1.0},
+		{"quiet", 100, 0.01},
+	}
+	for _, test := range tests {
+		analyzer := NewSpectrumAnalyzer(44100, 2048, 32)
+		freq := float64(test.bin) * analyzer.BinHz()
+
+		samples := make([]float64, 2048)
+		for i := range samples {
+			samples[i] = test.amplitude * math.Sin(2*math.Pi*freq*float64(i)/44100)
+		}
+		analyzer.Process(samples)
+
+		magnitudes := analyzer.Magnitudes()
+		peak := 0
+		for i := range magnitudes {
+			if magnitudes[i] > magnitudes[peak] {
+				peak = i
+			}
+		}
+		if peak != test.bin || analyzer.FrequencyToBin(freq) != test.bin {
+			t.Errorf("%s: peak in bin %d, want %d", test.name, peak, test.bin)
+		}
+		if got := magnitudes[peak]; math.Abs(got-test.amplitude) > 0.02*test.amplitude {
+			t.Errorf("%s: peak magnitude %.4f, want %.4f", test.name, got, test.amplitude)
+		}
+	}
+}
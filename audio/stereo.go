@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"math"
+)
+
+const (
+	// Smoothing for balance and correlation so they don't jitter per buffer
+	stereoSmoothing = 0.15
+)
+
+// StereoImage describes the stereo field of the first two channels
+type StereoImage struct {
+	Balance     float64 `json:"balance"`     // -1.0 (left) to 1.0 (right), 0 when centered or silent
+	Correlation float64 `json:"correlation"` // 1.0 mono, 0 uncorrelated/wide, -1.0 out of phase
+	Mid         float64 `json:"mid"`         // RMS of (L+R)/2
+	Side        float64 `json:"side"`        // RMS of (L-R)/2
+}
+
+// measureStereo computes balance, correlation and mid/side levels of a buffer.
+// Mono buffers are reported as centered and fully correlated
+func measureStereo(buffer [][]float32) StereoImage {
+	if len(buffer) == 0 || len(buffer[0]) == 0 {
+		return StereoImage{}
+	}
+
+	left := buffer[0]
+	if len(buffer) < 2 {
+		rms := 0.0
+		for _, s := range left {
+			rms += float64(s) * float64(s)
+		}
+		return StereoImage{Correlation: 1, Mid: math.Sqrt(rms / float64(len(left)))}
+	}
+	right := buffer[1]
+
+	var sumL, sumR, sumLR, sumMid, sumSide float64
+	for i := range left {
+		l, r := float64(left[i]), float64(right[i])
+		sumL += l * l
+		sumR += r * r
+		sumLR += l * r
+		mid, side := (l+r)/2, (l-r)/2
+		sumMid += mid * mid
+		sumSide += side * side
+	}
+
+	n := float64(len(left))
+	image := StereoImage{
+		Mid:  math.Sqrt(sumMid / n),
+		Side: math.Sqrt(sumSide / n),
+	}
+	if sumL+sumR > 0 {
+		image.Balance = (math.Sqrt(sumR) - math.Sqrt(sumL)) / (math.Sqrt(sumR) + math.Sqrt(sumL))
+	}
+	if sumL > 0 && sumR > 0 {
+		image.Correlation = sumLR / math.Sqrt(sumL*sumR)
+	}
+	return image
+}
+
+// smoothStereo blends a new measurement into the previous one
+func smoothStereo(previous, current StereoImage, dt float64) StereoImage {
+	k := 1 - math.Exp(-dt/stereoSmoothing)
+	return StereoImage{
+		Balance:     previous.Balance + (current.Balance-previous.Balance)*k,
+		Correlation: previous.Correlation + (current.Correlation-previous.Correlation)*k,
+		Mid:         current.Mid,
+		Side:        current.Side,
+	}
+}
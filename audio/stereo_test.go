@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureStereo(t *testing.T) {
+	// Eight whole cycles per buffer, so the sine and cosine are exactly uncorrelated
+	phase := func(i int) float64 { return 2 * math.Pi * 8 * float64(i) / defaultFramesPerBuffer }
+
+	tests := []struct {
+		name        string
+		left, right func(i int) float64
+		balance     float64
+		correlation float64
+	}{
+		{"in phase", func(i int) float64 { return 0.5 * math.Sin(phase(i)) }, func(i int) float64 { return 0.5 * math.Sin(phase(i)) }, 0, 1},
+		{"inverted", func(i int) float64 { return 0.5 * math.Sin(phase(i)) }, func(i int) float64 { return -0.5 * math.Sin(phase(i)) }, 0, -1},
+		{"quadrature", func(i int) float64 { return 0.5 * math.Sin(phase(i)) }, func(i int) float64 { return 0.5 * math.Cos(phase(i)) }, 0, 0},
+		{"panned right", func(i int) float64 { return 0.2 * math.Sin(phase(i)) }, func(i int) float64 { return 0.6 * math.Sin(phase(i)) }, 0.5, 1},
+		{"left only", func(i int) float64 { return 0.5 * math.Sin(phase(i)) }, func(i int) float64 { return 0 }, -1, 0},
+		{"silence", func(i int) float64 { return 0 }, func(i int) float64 { return 0 }, 0, 0},
+	}
+	for _, test := range tests {
+		buffer := [][]float32{make([]float32, defaultFramesPerBuffer), make([]float32, defaultFramesPerBuffer)}
+		for i := range buffer[0] {
+			buffer[0][i] = float32(test.left(i))
+			buffer[1][i] = float32(test.right(i))
+		}
+
+		image := measureStereo(buffer)
+		if math.Abs(image.Balance-test.balance) > 1e-4 {
+			t.Errorf("%s: balance = %.4f, want %.4f", test.name, image.Balance, test.balance)
+		}
+		if math.Abs(image.Correlation-test.correlation) > 1e-4 {
+			t.Errorf("%s: correlation = %.4f, want %.4f", test.name, image.Correlation, test.correlation)
+		}
+	}
+}
+
+func TestMeasureStereoMidSide(t *testing.T) {
+	tests := []struct {
+		name      string
+		sign      float32 // Right channel is sign times the left
+		mid, side float64
+	}{
+		{"in phase is all mid", 1, 0.5, 0},
+		{"inverted is all side", -1, 0, 0.5},
+	}
+	for _, test := range tests {
+		buffer := [][]float32{make([]float32, defaultFramesPerBuffer), make([]float32, defaultFramesPerBuffer)}
+		for i := range buffer[0] {
+			buffer[0][i] = 0.5
+			if i%2 == 1 {
+				buffer[0][i] = -0.5
+			}
+			buffer[1][i] = test.sign * buffer[0][i]
+		}
+
+		image := measureStereo(buffer)
+		if math.Abs(image.Mid-test.mid) > 1e-6 || math.Abs(image.Side-test.side) > 1e-6 {
+			t.Errorf("%s: mid %.4f side %.4f, want %.4f and %.4f", test.name, image.Mid, image.Side, test.mid, test.side)
+		}
+	}
+}
+
+func TestMeasureStereoMono(t *testing.T) {
+	buffer := [][]float32{{0.5, -0.5, 0.5, -0.5}}
+	image := measureStereo(buffer)
+	if image.Correlation != 1 || image.Balance != 0 || math.Abs(image.Mid-0.5) > 1e-6 {
+		t.Errorf("mono: %+v, want centered and fully correlated with mid 0.5", image)
+	}
+}
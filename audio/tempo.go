@@ -0,0 +1,183 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// TempoEstimator estimates tempo and beat phase by autocorrelating an onset envelope
+type TempoEstimator struct {
+	frameRate float64 // Onset envelope samples per second
+	size      int
+	envelope  []float64
+	times     []time.Time
+	average   float64 // Running mean used to detrend the onset signal
+
+	minBPM float64
+	maxBPM float64
+
+	bpm        float64
+	confidence float64
+	beatRef    time.Time // A moment a beat fell on
+
+	framesSinceUpdate int
+	updateEvery       int
+}
+
+// NewTempoEstimator creates an estimator for an onset signal arriving frameRate times per second
+func NewTempoEstimator(frameRate float64, window time.Duration) *TempoEstimator {
+	return &TempoEstimator{
+		frameRate:   frameRate,
+		size:        int(window.Seconds() * frameRate),
+		minBPM:      60,
+		maxBPM:      200,
+		updateEvery: int(frameRate / 2), // Re-estimate twice a second
+	}
+}
+
+// Process adds one onset strength sample (e.g. spectral flux) taken at the given time
+func (t *TempoEstimator) Process(onset float64, now time.Time) {
+	// Keep only the rise above the running mean
+	t.average += (onset - t.average) * 0.05
+	value := math.Max(onset-t.average, 0)
+
+	t.envelope = append(t.envelope, value)
+	t.times = append(t.times, now)
+	if len(t.envelope) > t.size {
+		t.envelope = t.envelope[1:]
+		t.times = t.times[1:]
+	}
+
+	t.framesSinceUpdate++
+	if t.framesSinceUpdate >= t.updateEvery && len(t.envelope) >= t.size/2 {
+		t.framesSinceUpdate = 0
+		t.estimate()
+	}
+}
+
+// estimate finds the dominant beat period and the offset of its beat grid
+func (t *TempoEstimator) estimate() {
+	n := len(t.envelope)
+
+	// Spread each onset over its neighbouring frames: a beat period that is not a whole
+	// number of frames puts onsets alternately one frame closer or further apart, which
+	// would otherwise only line up again at twice the period
+	env := make([]float64, n)
+	for i, v := range t.envelope {
+		env[i] += 0.5 * v
+		if i > 0 {
+			env[i-1] += 0.25 * v
+		}
+		if i+1 < n {
+			env[i+1] += 0.25 * v
+		}
+	}
+
+	energy := 0.0
+	for _, v := range env {
+		energy += v * v
+	}
+	if energy == 0 {
+		// Silence: forget the tempo
+		t.bpm = 0
+		t.confidence = 0
+		return
+	}
+
+	minLag := int(60 * t.frameRate / t.maxBPM)
+	maxLag := int(60 * t.frameRate / t.minBPM)
+	if maxLag >= n/2 {
+		maxLag = n/2 - 1
+	}
+	if minLag < 1 || maxLag <= minLag {
+		return
+	}
+
+	// Autocorrelation weighted towards musically common tempos around 120 BPM
+	scores := make([]float64, maxLag+2)
+	bestLag := 0
+	bestScore := 0.0
+	bestRaw := 0.0
+	for lag := minLag; lag <= maxLag+1 && lag < n; lag++ {
+		sum := 0.0
+		for i := lag; i < n; i++ {
+			sum += env[i] * env[i-lag]
+		}
+		sum /= float64(n - lag)
+		scores[lag] = sum
+
+		if lag > maxLag {
+			continue
+		}
+		bpm := 60 * t.frameRate / float64(lag)
+		octaves := math.Log2(bpm / 120)
+		weighted := sum * math.Exp(-0.5*octaves*octaves)
+		if weighted > bestScore {
+			bestScore = weighted
+			bestRaw = sum
+			bestLag = lag
+		}
+	}
+	if bestLag == 0 {
+		return
+	}
+
+	// Parabolic interpolation for a fractional lag; the tempo weighting can pick a lag on
+	// a slope of the raw scores, where the parabola would overshoot
+	period := float64(bestLag)
+	if bestLag > minLag && bestLag < maxLag {
+		a, b, c := scores[bestLag-1], scores[bestLag], scores[bestLag+1]
+		if denominator := a - 2*b + c; b >= a && b >= c && denominator != 0 {
+			period += 0.5 * (a - c) / denominator
+		}
+	}
+
+	bpm := 60 * t.frameRate / period
+	t.confidence = math.Min(bestRaw/(energy/float64(n)), 1.0)
+
+	// Follow small drifts smoothly, jump on real tempo changes
+	if t.bpm > 0 && math.Abs(bpm-t.bpm)/t.bpm < 0.05 {
+		t.bpm = t.bpm*0.8 + bpm*0.2
+	} else {
+		t.bpm = bpm
+	}
+
+	// Phase: find the offset of the beat grid that collects the most onset energy
+	bestOffset := 0
+	bestSum := -1.0
+	for offset := 0; offset < bestLag; offset++ {
+		sum := 0.0
+		for pos := float64(n - 1 - offset); pos >= 0; pos -= period {
+			sum += env[int(pos)]
+		}
+		if sum > bestSum {
+			bestSum = sum
+			bestOffset = offset
+		}
+	}
+	t.beatRef = t.times[n-1-bestOffset]
+}
+
+// BPM returns the estimated tempo, or 0 when no tempo has been found
+func (t *TempoEstimator) BPM() float64 {
+	return t.bpm
+}
+
+// Confidence returns how periodic the onset envelope is (0.0 - 1.0)
+func (t *TempoEstimator) Confidence() float64 {
+	return t.confidence
+}
+
+// Phase returns the position within the current beat at the given time (0.0 - 1.0)
+func (t *TempoEstimator) Phase(now time.Time) float64 {
+	if t.bpm <= 0 || t.beatRef.IsZero() {
+		return 0
+	}
+
+	beatLength := 60 / t.bpm
+	phase := math.Mod(now.Sub(t.beatRef).Seconds()/beatLength, 1.0)
+	if phase < 0 {
+		phase += 1.0
+	}
+	return phase
+}
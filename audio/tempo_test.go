@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestTempoFromPulseTrain(t *testing.T) {
+	frameRate := float64(defaultSampleRate) / defaultFramesPerBuffer
+	frameDuration := time.Duration(float64(time.Second) / frameRate)
+
+	for _, bpm := range []float64{90, 100, 120, 128, 140} {
+		estimator := NewTempoEstimator(frameRate, tempoWindow)
+		start := time.Unix(0, 0)
+		beatLength := 60 / bpm
+
+		// One onset frame per beat, the first at 0.3 s
+		var now time.Time
+		for frame := 0; frame < int(12*frameRate); frame++ {
+			now = start.Add(time.Duration(frame) * frameDuration)
+			beat := (now.Sub(start).Seconds() - 0.3) / beatLength
+			onset := 0.0
+			if beat >= 0 && beat-math.Floor(beat) < 1/(frameRate*beatLength) {
+				onset = 1
+			}
+			estimator.Process(onset, now)
+		}
+
+		if got := estimator.BPM(); math.Abs(got-bpm) > 2 {
+			t.Errorf("%.0f BPM: estimated %.1f BPM", bpm, got)
+			continue
+		}
+		if confidence := estimator.Confidence(); confidence < 0.5 {
+			t.Errorf("%.0f BPM: confidence %.2f, want a confident estimate of a strict pulse", bpm, confidence)
+		}
+
+		// The beat grid lines up with the pulses within a frame
+		beat := start.Add(300*time.Millisecond + time.Duration(20*beatLength*float64(time.Second)))
+		phase := estimator.Phase(beat)
+		if distance := math.Min(phase, 1-phase) * beatLength; distance > 1.5/frameRate {
+			t.Errorf("%.0f BPM: phase %.2f on a pulse, want about 0", bpm, phase)
+		}
+	}
+}
+
+func TestTempoFromClickTrain(t *testing.T) {
+	for _, bpm := range []float64{90, 120, 140, 174} {
+		interval := time.Duration(60 / bpm * float64(time.Second))
+		samples := clickTrain(12*time.Second, 300*time.Millisecond, interval, rand.New(rand.NewSource(1)))
+
+		analyzer := NewSpectrumAnalyzer(defaultSampleRate, 2048, 32)
+		detector := NewBeatDetector(64)
+		estimator := NewTempoEstimator(float64(defaultSampleRate)/defaultFramesPerBuffer, tempoWindow)
+		start := time.Unix(0, 0)
+		for pos := 0; pos+defaultFramesPerBuffer <= len(samples); pos += defaultFramesPerBuffer {
+			analyzer.Process(samples[pos : pos+defaultFramesPerBuffer])
+			now := start.Add(time.Duration(float64(pos+defaultFramesPerBuffer) / defaultSampleRate * float64(time.Second)))
+			detector.Process(analyzer.Magnitudes(), now)
+			estimator.Process(detector.Flux(), now)
+		}
+
+		if got := estimator.BPM(); math.Abs(got-bpm) > 2 {
+			t.Errorf("%.0f BPM: estimated %.1f BPM", bpm, got)
+		}
+	}
+}
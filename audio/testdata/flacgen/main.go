@@ -0,0 +1,593 @@
+// Command flacgen writes the FLAC fixtures used by the decoder tests, together with the
+// samples they encode as interleaved little-endian int32 (.s32). Run it from the module
+// root with "go run ./audio/testdata/flacgen" after changing the fixtures below
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// Subframe kinds
+const (
+	subConstant = iota
+	subVerbatim
+	subFixed
+	subLPC
+)
+
+// subframe describes how one channel of a frame is encoded
+type subframe struct {
+	kind      int
+	order     int
+	wasted    int // Low bits known to be zero in the signal
+	precision int // LPC coefficient precision
+	residual  residual
+}
+
+// residual describes the partitioned Rice coding of a subframe's residual
+type residual struct {
+	method         int // 0: 4-bit Rice parameters, 1: 5-bit
+	partitionOrder int
+	escape         int // Escape every n-th partition to unencoded samples, 0 never
+}
+
+// frame describes one audio frame and the signal of each channel
+type frame struct {
+	blockSizeCode int
+	size          int
+	rateCode      int
+	sizeCode      int
+	channelCode   int
+	signals       []string // Per input channel: tone, noise, constant, even or flat
+	subframes     []subframe
+}
+
+// fixture is one FLAC file
+type fixture struct {
+	name     string
+	rate     int
+	channels int
+	bps      int
+	frames   []frame
+	corrupt  bool // No reference samples are written for corrupt files
+}
+
+// Shorthands for the fixture tables
+func fixed(order, partitionOrder int) subframe {
+	return subframe{kind: subFixed, order: order, residual: residual{partitionOrder: partitionOrder}}
+}
+
+func lpc(order, precision, partitionOrder int) subframe {
+	return subframe{kind: subLPC, order: order, precision: precision, residual: residual{partitionOrder: partitionOrder}}
+}
+
+func escaped(s subframe, method, every int) subframe {
+	s.residual.method = method
+	s.residual.escape = every
+	return s
+}
+
+var stereo = []string{"tone", "tone"}
+
+var fixtures = []fixture{
+	{name: "fixed", rate: 44100, channels: 2, bps: 16, frames: []frame{
+		{blockSizeCode: 1, size: 192, rateCode: 9, sizeCode: 4, channelCode: 1, signals: []string{"constant", "tone"},
+			subframes: []subframe{{kind: subConstant}, {kind: subVerbatim}}},
+		{blockSizeCode: 2, size: 576, rateCode: 9, sizeCode: 4, channelCode: 1, signals: stereo,
+			subframes: []subframe{fixed(0, 0), fixed(1, 2)}},
+		{blockSizeCode: 10, size: 1024, rateCode: 0, sizeCode: 0, channelCode: 1, signals: stereo,
+			subframes: []subframe{fixed(2, 3), fixed(3, 4)}},
+		{blockSizeCode: 7, size: 1000, rateCode: 9, sizeCode: 4, channelCode: 1, signals: []string{"tone", "even"},
+			subframes: []subframe{fixed(4, 1), {kind: subFixed, order: 2, wasted: 2, residual: residual{partitionOrder: 2}}}},
+		{blockSizeCode: 6, size: 100, rateCode: 9, sizeCode: 4, channelCode: 1, signals: []string{"tone", "even"},
+			subframes: []subframe{fixed(2, 0), {kind: subVerbatim, wasted: 1}}},
+	}},
+	{name: "lpc", rate: 48000, channels: 1, bps: 24, frames: []frame{
+		{blockSizeCode: 12, size: 4096, rateCode: 10, sizeCode: 6, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{lpc(2, 12, 0)}},
+		{blockSizeCode: 3, size: 1152, rateCode: 0, sizeCode: 0, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{lpc(8, 15, 4)}},
+		{blockSizeCode: 7, size: 3000, rateCode: 13, sizeCode: 6, channelCode: 0, signals: []string{"noise"},
+			subframes: []subframe{escaped(lpc(1, 5, 3), 1, 0)}},
+		{blockSizeCode: 8, size: 256, rateCode: 12, sizeCode: 6, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{lpc(32, 14, 1)}},
+		{blockSizeCode: 6, size: 37, rateCode: 10, sizeCode: 6, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{lpc(12, 13, 0)}},
+	}},
+	{name: "left_side", rate: 44100, channels: 2, bps: 16, frames: []frame{
+		{blockSizeCode: 12, size: 4096, rateCode: 9, sizeCode: 4, channelCode: 8, signals: stereo,
+			subframes: []subframe{fixed(2, 2), lpc(4, 12, 2)}},
+		{blockSizeCode: 6, size: 200, rateCode: 9, sizeCode: 4, channelCode: 8, signals: []string{"noise", "noise"},
+			subframes: []subframe{{kind: subVerbatim}, {kind: subVerbatim}}},
+	}},
+	{name: "right_side", rate: 44100, channels: 2, bps: 16, frames: []frame{
+		{blockSizeCode: 12, size: 4096, rateCode: 9, sizeCode: 4, channelCode: 9, signals: stereo,
+			subframes: []subframe{lpc(4, 12, 2), fixed(2, 2)}},
+		{blockSizeCode: 6, size: 200, rateCode: 9, sizeCode: 4, channelCode: 9, signals: []string{"noise", "noise"},
+			subframes: []subframe{{kind: subVerbatim}, {kind: subVerbatim}}},
+	}},
+	{name: "mid_side", rate: 44100, channels: 2, bps: 16, frames: []frame{
+		{blockSizeCode: 12, size: 4096, rateCode: 9, sizeCode: 4, channelCode: 10, signals: stereo,
+			subframes: []subframe{lpc(8, 12, 3), fixed(1, 2)}},
+		{blockSizeCode: 6, size: 200, rateCode: 9, sizeCode: 4, channelCode: 10, signals: []string{"noise", "noise"},
+			subframes: []subframe{{kind: subVerbatim}, {kind: subVerbatim}}},
+	}},
+	{name: "rice_escape", rate: 44100, channels: 2, bps: 16, frames: []frame{
+		{blockSizeCode: 11, size: 2048, rateCode: 9, sizeCode: 4, channelCode: 1, signals: []string{"flat", "tone"},
+			subframes: []subframe{escaped(fixed(1, 2), 0, 1), escaped(fixed(2, 3), 0, 2)}},
+		{blockSizeCode: 11, size: 2048, rateCode: 9, sizeCode: 4, channelCode: 1, signals: []string{"flat", "noise"},
+			subframes: []subframe{escaped(fixed(1, 2), 1, 1), escaped(fixed(0, 1), 1, 0)}},
+		{blockSizeCode: 11, size: 2048, rateCode: 9, sizeCode: 4, channelCode: 10, signals: stereo,
+			subframes: []subframe{escaped(lpc(2, 12, 4), 1, 3), escaped(fixed(3, 0), 0, 1)}},
+	}},
+	{name: "corrupt_order", rate: 44100, channels: 1, bps: 16, corrupt: true, frames: []frame{
+		{blockSizeCode: 6, size: 2, rateCode: 9, sizeCode: 4, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{fixed(4, 0)}},
+	}},
+	{name: "corrupt_partitions", rate: 44100, channels: 1, bps: 16, corrupt: true, frames: []frame{
+		{blockSizeCode: 6, size: 100, rateCode: 9, sizeCode: 4, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{fixed(2, 3)}},
+		{blockSizeCode: 6, size: 100, rateCode: 9, sizeCode: 4, channelCode: 0, signals: []string{"tone"},
+			subframes: []subframe{fixed(2, 0)}},
+	}},
+}
+
+func main() {
+	dir := filepath.Join("audio", "testdata", "flac")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, f := range fixtures {
+		data, samples := f.encode()
+		if err := os.WriteFile(filepath.Join(dir, f.name+".flac"), data, 0644); err != nil {
+			log.Fatal(err)
+		}
+		if f.corrupt {
+			continue
+		}
+		raw := make([]byte, 0, len(samples)*4)
+		for _, v := range samples {
+			raw = binary.LittleEndian.AppendUint32(raw, uint32(int32(v)))
+		}
+		if err := os.WriteFile(filepath.Join(dir, f.name+".s32"), raw, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// encode returns the FLAC file and its interleaved samples
+func (f fixture) encode() ([]byte, []int64) {
+	random := rand.New(rand.NewSource(int64(len(f.name))))
+	var interleaved []int64
+	var frames []byte
+	position := 0
+	minBlock, maxBlock := 1<<16-1, 0
+
+	for number, fr := range f.frames {
+		channels := make([][]int64, f.channels)
+		for ch := range channels {
+			channels[ch] = f.signal(fr.signals[ch], ch, position, fr.size, random)
+		}
+		for i := 0; i < fr.size; i++ {
+			for ch := range channels {
+				interleaved = append(interleaved, channels[ch][i])
+			}
+		}
+		frames = append(frames, f.encodeFrame(number, fr, channels)...)
+		position += fr.size
+		minBlock = min(minBlock, fr.size)
+		maxBlock = max(maxBlock, fr.size)
+	}
+
+	w := &bitWriter{}
+	w.bytes([]byte("fLaC"))
+
+	// STREAMINFO followed by a padding block
+	w.write(0, 1)
+	w.write(0, 7)
+	w.write(34, 24)
+	w.write(uint64(minBlock), 16)
+	w.write(uint64(maxBlock), 16)
+	w.write(0, 24)
+	w.write(0, 24)
+	w.write(uint64(f.rate), 20)
+	w.write(uint64(f.channels-1), 3)
+	w.write(uint64(f.bps-1), 5)
+	w.write(uint64(position), 36)
+	w.bytes(f.signature(interleaved))
+	w.write(1, 1)
+	w.write(1, 7)
+	w.write(8, 24)
+	w.bytes(make([]byte, 8))
+
+	return append(w.data, frames...), interleaved
+}
+
+// signal generates size samples of a channel starting at position
+func (f fixture) signal(kind string, ch, position, size int, random *rand.Rand) []int64 {
+	peak := float64(int64(1) << (f.bps - 1))
+	out := make([]int64, size)
+	for i := range out {
+		t := float64(position+i) / float64(f.rate)
+		tone := 0.6*peak*math.Sin(2*math.Pi*float64(220*(ch+2))*t) + peak/200*(random.Float64()*2-1)
+		switch kind {
+		case "tone":
+			out[i] = int64(tone)
+		case "noise":
+			out[i] = random.Int63n(int64(peak)*2) - int64(peak)
+		case "constant":
+			out[i] = int64(peak / 3)
+		case "even":
+			out[i] = int64(tone) &^ 3
+		case "flat":
+			// Silence for half the block gives all-zero residual partitions
+			if i >= size/2 {
+				out[i] = int64(tone)
+			}
+		default:
+			log.Fatalf("unknown signal %q", kind)
+		}
+	}
+	return out
+}
+
+// signature returns the MD5 of the samples as little-endian bytes of the sample size
+func (f fixture) signature(samples []int64) []byte {
+	width := (f.bps + 7) / 8
+	raw := make([]byte, 0, len(samples)*width)
+	for _, v := range samples {
+		for b := 0; b < width; b++ {
+			raw = append(raw, byte(v>>(8*b)))
+		}
+	}
+	sum := md5.Sum(raw)
+	return sum[:]
+}
+
+// encodeFrame encodes one frame, decorrelating the channels as its channel code asks
+func (f fixture) encodeFrame(number int, fr frame, channels [][]int64) []byte {
+	header := &bitWriter{}
+	header.write(0x3FFE, 14)
+	header.write(0, 2)
+	header.write(uint64(fr.blockSizeCode), 4)
+	header.write(uint64(fr.rateCode), 4)
+	header.write(uint64(fr.channelCode), 4)
+	header.write(uint64(fr.sizeCode), 3)
+	header.write(0, 1)
+	header.bytes(utf8.AppendRune(nil, rune(number)))
+	switch fr.blockSizeCode {
+	case 6:
+		header.write(uint64(fr.size-1), 8)
+	case 7:
+		header.write(uint64(fr.size-1), 16)
+	}
+	switch fr.rateCode {
+	case 12:
+		header.write(uint64(f.rate/1000), 8)
+	case 13:
+		header.write(uint64(f.rate), 16)
+	case 14:
+		header.write(uint64(f.rate/10), 16)
+	}
+	header.bytes([]byte{crc8(header.data)})
+
+	encoded := channels
+	bps := []int{f.bps, f.bps}
+	if fr.channelCode >= 8 {
+		left, right := channels[0], channels[1]
+		side := make([]int64, len(left))
+		mid := make([]int64, len(left))
+		for i := range left {
+			side[i] = left[i] - right[i]
+			mid[i] = (left[i] + right[i]) >> 1
+		}
+		switch fr.channelCode {
+		case 8:
+			encoded, bps = [][]int64{left, side}, []int{f.bps, f.bps + 1}
+		case 9:
+			encoded, bps = [][]int64{side, right}, []int{f.bps + 1, f.bps}
+		case 10:
+			encoded, bps = [][]int64{mid, side}, []int{f.bps, f.bps + 1}
+		}
+	}
+
+	body := &bitWriter{data: header.data}
+	for ch, samples := range encoded {
+		width := f.bps
+		if ch < len(bps) {
+			width = bps[ch]
+		}
+		body.subframe(fr.subframes[ch], samples, width)
+	}
+	body.align()
+	crc := crc16(body.data)
+	body.write(uint64(crc), 16)
+	return body.data
+}
+
+// subframe encodes the samples of one channel
+func (w *bitWriter) subframe(s subframe, samples []int64, bps int) {
+	w.write(0, 1)
+	switch s.kind {
+	case subConstant:
+		w.write(0, 6)
+	case subVerbatim:
+		w.write(1, 6)
+	case subFixed:
+		w.write(uint64(8+s.order), 6)
+	case subLPC:
+		w.write(uint64(31+s.order), 6)
+	}
+
+	if s.wasted > 0 {
+		w.write(1, 1)
+		w.unary(uint64(s.wasted - 1))
+		shifted := make([]int64, len(samples))
+		for i, v := range samples {
+			if v&(1<<s.wasted-1) != 0 {
+				log.Fatalf("sample %d has no %d wasted bits", v, s.wasted)
+			}
+			shifted[i] = v >> s.wasted
+		}
+		samples = shifted
+		bps -= s.wasted
+	} else {
+		w.write(0, 1)
+	}
+
+	// Corrupt fixtures may ask for more warm-up samples than the block holds
+	warmUp := min(s.order, len(samples))
+
+	switch s.kind {
+	case subConstant:
+		w.signed(samples[0], bps)
+	case subVerbatim:
+		for _, v := range samples {
+			w.signed(v, bps)
+		}
+	case subFixed:
+		for _, v := range samples[:warmUp] {
+			w.signed(v, bps)
+		}
+		w.residual(s.residual, fixedResidual(samples, s.order), len(samples), s.order)
+	case subLPC:
+		for _, v := range samples[:warmUp] {
+			w.signed(v, bps)
+		}
+		coefficients, shift := quantize(levinson(samples, s.order), s.precision)
+		w.write(uint64(s.precision-1), 4)
+		w.signed(int64(shift), 5)
+		for _, c := range coefficients {
+			w.signed(c, s.precision)
+		}
+		var residual []int64
+		for i := s.order; i < len(samples); i++ {
+			sum := int64(0)
+			for j, c := range coefficients {
+				sum += c * samples[i-1-j]
+			}
+			residual = append(residual, samples[i]-sum>>shift)
+		}
+		w.residual(s.residual, residual, len(samples), s.order)
+	}
+}
+
+// residual writes the partitioned residual the way the decoder splits it, even when the
+// partitions do not cover the block
+func (w *bitWriter) residual(r residual, values []int64, blockSize, order int) {
+	w.write(uint64(r.method), 2)
+	w.write(uint64(r.partitionOrder), 4)
+	paramBits := 4 + r.method
+	escape := uint64(1)<<paramBits - 1
+
+	partitionSize := blockSize >> r.partitionOrder
+	pos := 0
+	for p := 0; p < 1<<r.partitionOrder; p++ {
+		count := partitionSize
+		if p == 0 {
+			count -= order
+		}
+		count = max(count, 0)
+		part := values[pos : pos+count]
+		pos += count
+
+		if r.escape > 0 && p%r.escape == 0 {
+			size := 0
+			for _, v := range part {
+				size = max(size, bitsFor(v))
+			}
+			w.write(escape, paramBits)
+			w.write(uint64(size), 5)
+			for _, v := range part {
+				w.signed(v, size)
+			}
+			continue
+		}
+
+		total := int64(0)
+		for _, v := range part {
+			total += abs(v)
+		}
+		param := 0
+		if len(part) > 0 {
+			for mean := total / int64(len(part)); mean > 1 && uint64(param) < escape-1; mean >>= 1 {
+				param++
+			}
+		}
+		w.write(uint64(param), paramBits)
+		for _, v := range part {
+			u := uint64(v<<1) ^ uint64(v>>63)
+			w.unary(u >> param)
+			w.write(u&(1<<param-1), param)
+		}
+	}
+}
+
+// fixedResidual applies FLAC's fixed polynomial predictor of the given order
+func fixedResidual(s []int64, order int) []int64 {
+	var out []int64
+	for i := order; i < len(s); i++ {
+		var prediction int64
+		switch order {
+		case 1:
+			prediction = s[i-1]
+		case 2:
+			prediction = 2*s[i-1] - s[i-2]
+		case 3:
+			prediction = 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			prediction = 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+		out = append(out, s[i]-prediction)
+	}
+	return out
+}
+
+// levinson returns linear prediction coefficients of the given order for the samples
+func levinson(samples []int64, order int) []float64 {
+	autocorrelation := make([]float64, order+1)
+	for lag := range autocorrelation {
+		for i := lag; i < len(samples); i++ {
+			autocorrelation[lag] += float64(samples[i]) * float64(samples[i-lag])
+		}
+	}
+	autocorrelation[0] *= 1.0001
+
+	coefficients := make([]float64, order)
+	errorPower := autocorrelation[0]
+	for i := 0; i < order && errorPower > 0; i++ {
+		reflection := autocorrelation[i+1]
+		for j := 0; j < i; j++ {
+			reflection -= coefficients[j] * autocorrelation[i-j]
+		}
+		reflection /= errorPower
+
+		previous := append([]float64(nil), coefficients...)
+		coefficients[i] = reflection
+		for j := 0; j < i; j++ {
+			coefficients[j] = previous[j] - reflection*previous[i-1-j]
+		}
+		errorPower *= 1 - reflection*reflection
+	}
+	return coefficients
+}
+
+// quantize converts coefficients to integers of the given precision and their shift
+func quantize(coefficients []float64, precision int) ([]int64, int) {
+	largest := 0.0
+	for _, c := range coefficients {
+		largest = math.Max(largest, math.Abs(c))
+	}
+	_, exponent := math.Frexp(largest)
+	shift := min(max(precision-1-exponent, 0), 15)
+
+	limit := int64(1) << (precision - 1)
+	out := make([]int64, len(coefficients))
+	for i, c := range coefficients {
+		out[i] = min(max(int64(math.Round(c*float64(int64(1)<<shift))), -limit), limit-1)
+	}
+	return out, shift
+}
+
+// bitsFor returns the size of the smallest two's complement field holding v
+func bitsFor(v int64) int {
+	if v == 0 {
+		return 0
+	}
+	bits := 1
+	for v < -(int64(1)<<bits)/2 || v > (int64(1)<<bits)/2-1 {
+		bits++
+	}
+	return bits
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// bitWriter writes big-endian bit fields
+type bitWriter struct {
+	data []byte
+	n    int // Bits used in the last byte, 0 when aligned
+}
+
+func (w *bitWriter) write(v uint64, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.n)
+		w.n = (w.n + 1) % 8
+	}
+}
+
+func (w *bitWriter) signed(v int64, bits int) {
+	if bits == 0 {
+		return
+	}
+	if bitsFor(v) > bits {
+		log.Fatal(fmt.Errorf("%d does not fit in %d bits", v, bits))
+	}
+	w.write(uint64(v)&(1<<bits-1), bits)
+}
+
+func (w *bitWriter) unary(v uint64) {
+	for ; v > 0; v-- {
+		w.write(0, 1)
+	}
+	w.write(1, 1)
+}
+
+func (w *bitWriter) bytes(b []byte) {
+	for _, c := range b {
+		w.write(uint64(c), 8)
+	}
+}
+
+func (w *bitWriter) align() {
+	w.n = 0
+}
+
+// crc8 is the frame header checksum (polynomial 0x07)
+func crc8(data []byte) byte {
+	crc := byte(0)
+	for _, c := range data {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 is the frame checksum (polynomial 0x8005)
+func crc16(data []byte) uint16 {
+	crc := uint16(0)
+	for _, c := range data {
+		crc ^= uint16(c) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
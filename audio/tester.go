@@ -2,6 +2,7 @@ package audio
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,6 +21,13 @@ func NewTester() *Tester {
 	}
 }
 
+// NewTesterWithSource creates a tester that monitors the given source instead of a capture device
+func NewTesterWithSource(source Source) *Tester {
+	return &Tester{
+		player: NewPlayerWithSource(source),
+	}
+}
+
 // Run starts the audio test
 func (t *Tester) Run() {
 	fmt.Println("AUDIO CAPTURE TEST")
@@ -81,3 +89,161 @@ func (t *Tester) monitorAudio() {
 		}
 	}
 }
+
+// SelfTest feeds generated signals through the analysis chain faster than real time
+// and reports whether levels, spectrum, beats and tempo respond as expected
+func (t *Tester) SelfTest() bool {
+	fmt.Println("ANALYSIS SELF-TEST")
+	fmt.Println("==================")
+
+	checks := []struct {
+		name  string
+		check func() error
+	}{
+		{"Silence", checkSilence},
+		{"Sine sweep", checkSweep},
+		{"Pink noise", checkPink},
+		{"Kick @ 120 BPM", func() error { return checkKick(120) }},
+		{"Kick @ 90 BPM", func() error { return checkKick(90) }},
+	}
+
+	passed := true
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			fmt.Printf("❌ %-16s %v\n", c.name, err)
+			passed = false
+		} else {
+			fmt.Printf("✅ %s\n", c.name)
+		}
+	}
+
+	if passed {
+		fmt.Println("\nAnalysis chain OK")
+	} else {
+		fmt.Println("\nAnalysis chain has problems")
+	}
+	return passed
+}
+
+// selfTestWarmup is how long the beat detector needs to fill its history
+const selfTestWarmup = 2 * time.Second
+
+// runSignal feeds a generated signal through a fresh player, calling observe after every buffer.
+// Returns the number of beats detected after the warm-up
+func runSignal(signal Signal, bpm float64, duration time.Duration, observe func(*Player)) (*Player, int, error) {
+	generator, err := NewGeneratorSource(signal, bpm)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Advance a synthetic clock so time-based analysis behaves as in real time
+	start := time.Now()
+	player := NewPlayer()
+	beats := 0
+	player.SetBeatCallback(func(beat BeatEvent) {
+		if beat.Time.Sub(start) >= selfTestWarmup {
+			beats++
+		}
+	})
+
+	buffer := make([][]float32, generator.Channels())
+	for ch := range buffer {
+		buffer[ch] = make([]float32, generator.FramesPerBuffer())
+	}
+
+	bufferDuration := time.Duration(float64(generator.FramesPerBuffer()) / generator.SampleRate() * float64(time.Second))
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += bufferDuration {
+		generator.Generate(buffer)
+		player.ProcessBuffer(buffer, start.Add(elapsed))
+		if observe != nil {
+			observe(player)
+		}
+	}
+
+	return player, beats, nil
+}
+
+// checkSilence expects no level, beats or tempo from digital silence
+func checkSilence() error {
+	player, beats, err := runSignal(SignalSilence, 0, 5*time.Second, nil)
+	if err != nil {
+		return err
+	}
+	if peak := player.GetPeakLevel(); peak != 0 {
+		return fmt.Errorf("peak %.4f, expected 0", peak)
+	}
+	if beats > 0 {
+		return fmt.Errorf("%d beats detected in silence", beats)
+	}
+	if tempo := player.GetTempo(); tempo != 0 {
+		return fmt.Errorf("tempo %.1f BPM detected in silence", tempo)
+	}
+	return nil
+}
+
+// checkSweep expects the loudest spectrum bin to climb as the sweep rises
+func checkSweep() error {
+	var loudest []int
+	_, _, err := runSignal(SignalSweep, 0, time.Duration(sweepDuration*float64(time.Second)), func(p *Player) {
+		best := 0
+		spectrum := p.GetSpectrum()
+		for i, magnitude := range spectrum {
+			if magnitude > spectrum[best] {
+				best = i
+			}
+		}
+		loudest = append(loudest, best)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Compare the first and last tenth of the sweep
+	tenth := len(loudest) / 10
+	start, end := 0.0, 0.0
+	for i := 0; i < tenth; i++ {
+		start += float64(loudest[i])
+		end += float64(loudest[len(loudest)-1-i])
+	}
+	if end <= start {
+		return fmt.Errorf("spectrum peak did not rise (bin %.1f -> %.1f)", start/float64(tenth), end/float64(tenth))
+	}
+	return nil
+}
+
+// checkPink expects steady energy in every band
+func checkPink() error {
+	player, _, err := runSignal(SignalPink, 0, 3*time.Second, nil)
+	if err != nil {
+		return err
+	}
+
+	for name, energy := range player.GetBandEnergies() {
+		if energy <= 0.001 {
+			return fmt.Errorf("no energy in the %s band", name)
+		}
+	}
+	if peak := player.GetPeakLevel(); peak < 0.1 {
+		return fmt.Errorf("peak %.4f is too low", peak)
+	}
+	return nil
+}
+
+// checkKick expects a beat for most kicks and a tempo estimate close to the generated BPM
+func checkKick(bpm float64) error {
+	duration := 12 * time.Second
+	player, beats, err := runSignal(SignalKick, bpm, duration, nil)
+	if err != nil {
+		return err
+	}
+
+	expected := (duration - selfTestWarmup).Minutes() * bpm
+	// Onsets that straddle two analysis frames are occasionally too weak, so allow a few misses
+	if float64(beats) < expected*0.8 || float64(beats) > expected*1.1 {
+		return fmt.Errorf("%d beats detected, expected about %.0f", beats, expected)
+	}
+	if tempo := player.GetTempo(); math.Abs(tempo-bpm) > 3 {
+		return fmt.Errorf("tempo %.1f BPM, expected %.0f", tempo, bpm)
+	}
+	return nil
+}
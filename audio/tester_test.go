@@ -0,0 +1,9 @@
+package audio
+
+import "testing"
+
+func TestSelfTest(t *testing.T) {
+	if !NewTester().SelfTest() {
+		t.Fatal("analysis self-test failed, see the output above")
+	}
+}
@@ -0,0 +1,237 @@
+package audio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+const (
+	// No buffers for this long means the stream died (device unplugged, sound server restarted)
+	stallTimeout = 2 * time.Second
+
+	// How often the watchdog checks the stream
+	watchdogInterval = 500 * time.Millisecond
+
+	// Pause between attempts to reopen a failed stream
+	recoveryRetry = 2 * time.Second
+
+	// How often to look for the chosen device while capturing from a fallback. Capture is
+	// only reopened, with a brief interruption, once PortAudio lists the device again
+	preferredDeviceRetry = 10 * time.Second
+)
+
+// StreamState describes the health of the capture stream
+type StreamState int
+
+const (
+	StreamStopped StreamState = iota
+	StreamRunning
+	StreamStalled    // No buffers are arriving
+	StreamRecovering // Reopening the stream
+	StreamFailed     // Reopening failed; will retry
+	StreamFinished   // The source ended, e.g. a file played to the end
+)
+
+// String returns a short lowercase name for the state
+func (s StreamState) String() string {
+	switch s {
+	case StreamStopped:
+		return "stopped"
+	case StreamRunning:
+		return "running"
+	case StreamStalled:
+		return "stalled"
+	case StreamRecovering:
+		return "recovering"
+	case StreamFailed:
+		return "failed"
+	case StreamFinished:
+		return "finished"
+	default:
+		return "unknown"
+	}
+}
+
+// startWatchdog starts monitoring the stream for stalls
+func (p *Player) startWatchdog() {
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
+	if p.watchdogStop != nil {
+		return
+	}
+	p.watchdogStop = make(chan struct{})
+	go p.watchdog(p.watchdogStop)
+}
+
+// watchdog periodically checks the stream until stop is closed
+func (p *Player) watchdog(stop chan struct{}) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.checkStream(now)
+		}
+	}
+}
+
+// checkStream detects stalled or failed streams, keeps the analysis decaying
+// and tries to reopen PortAudio devices
+func (p *Player) checkStream(now time.Time) {
+	p.streamMutex.Lock()
+	defer p.streamMutex.Unlock()
+
+	// Cleanup already ran
+	if p.watchdogStop == nil || !p.running {
+		return
+	}
+
+	// Nothing more will arrive, so this is not a stall; don't pad the recording with silence
+	if p.IsFinished() {
+		p.setStreamState(StreamFinished)
+		return
+	}
+
+	p.mutex.RLock()
+	state := p.streamState
+	stalled := now.Sub(p.lastBufferTime) > stallTimeout
+	p.mutex.RUnlock()
+
+	if !stalled && state != StreamFailed {
+		// Buffers are arriving (again)
+		if state != StreamRunning {
+			p.setStreamState(StreamRunning)
+		}
+		if p.onFallbackDevice() && now.Sub(p.lastRecovery) >= preferredDeviceRetry {
+			// Switch back once the chosen device is present again
+			p.lastRecovery = now
+			if p.preferredDevicePresent() {
+				if err := p.recoverStream(); err != nil {
+					p.setStreamState(StreamFailed)
+				}
+			}
+		}
+		return
+	}
+	if state == StreamRunning {
+		p.setStreamState(StreamStalled)
+	}
+
+	// Feed silence so the visualizer decays and goes idle instead of freezing at the last peak
+	p.feedSilence(now)
+
+	// Files and generators can't be reopened; they just stay stalled
+	if p.externalSource {
+		return
+	}
+	if now.Sub(p.lastRecovery) < recoveryRetry {
+		return
+	}
+	p.lastRecovery = now
+
+	if err := p.recoverStream(); err != nil {
+		p.setStreamState(StreamFailed)
+	}
+}
+
+// feedSilence runs one watchdog interval worth of silent buffers through the analysis.
+// The silence is not recorded; a gap in the recording is more honest than made-up audio
+func (p *Player) feedSilence(now time.Time) {
+	config := p.GetStreamConfig()
+	if config.SampleRate <= 0 || config.FramesPerBuffer <= 0 || config.Channels <= 0 {
+		return
+	}
+
+	buffer := make([][]float32, config.Channels)
+	for ch := range buffer {
+		buffer[ch] = make([]float32, config.FramesPerBuffer)
+	}
+
+	bufferDuration := time.Duration(float64(config.FramesPerBuffer) / config.SampleRate * float64(time.Second))
+	count := int(watchdogInterval / bufferDuration)
+	for i := 0; i < count; i++ {
+		p.process(buffer, now.Add(-time.Duration(count-1-i)*bufferDuration), false)
+	}
+}
+
+// onFallbackDevice returns whether recovery left us on another device than the chosen one
+func (p *Player) onFallbackDevice() bool {
+	return !p.externalSource && p.preferredDevice != "" && p.GetCurrentDeviceName() != p.preferredDevice
+}
+
+// preferredDevicePresent returns whether PortAudio lists the chosen device as an input
+func (p *Player) preferredDevicePresent() bool {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return false
+	}
+	for _, device := range devices {
+		if device.Name == p.preferredDevice && device.MaxInputChannels > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverStream reinitializes PortAudio and reopens the chosen device, else the current
+// one, else the best remaining one; the caller must hold the stream mutex
+func (p *Player) recoverStream() error {
+	p.setStreamState(StreamRecovering)
+	previous := p.GetCurrentDeviceName()
+
+	// Closing aborts the stream; stopping a dead stream can hang
+	if p.source != nil {
+		p.source.Close()
+	}
+
+	// PortAudio only sees added or removed devices after reinitializing
+	if p.paInitialized {
+		portaudio.Terminate()
+		p.paInitialized = false
+	}
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize PortAudio: %v", err)
+	}
+	p.paInitialized = true
+
+	if _, err := p.refreshDevices(); err != nil {
+		return err
+	}
+
+	device := p.findInputDevice(p.preferredDevice)
+	if device == nil {
+		device = p.findInputDevice(previous)
+	}
+	if device == nil {
+		var err error
+		if device, err = p.bestDevice(p.monitorSource); err != nil {
+			return err
+		}
+	}
+
+	p.setCurrentDevice(device)
+	if err := p.openStream(device); err != nil {
+		return err
+	}
+	return p.start()
+}
+
+// setStreamState records the stream health
+func (p *Player) setStreamState(state StreamState) {
+	p.mutex.Lock()
+	p.streamState = state
+	p.mutex.Unlock()
+}
+
+// GetStreamState returns the health of the capture stream
+func (p *Player) GetStreamState() StreamState {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.streamState
+}
@@ -0,0 +1,227 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// WAV format tags
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// audioDecoder streams decoded audio from a file
+type audioDecoder interface {
+	// read fills buffer (one slice per channel) and returns the number of frames read, io.EOF at the end
+	read(buffer [][]float32) (int, error)
+	sampleRate() float64
+	channels() int
+	close() error
+}
+
+// openDecoder opens a WAV or FLAC file, detected from its first bytes
+func openDecoder(path string) (audioDecoder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	magic, err := reader.Peek(4)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var decoder audioDecoder
+	switch string(magic) {
+	case "RIFF":
+		decoder, err = newWAVDecoder(file, reader)
+	case "fLaC":
+		decoder, err = newFLACDecoder(file, reader)
+	default:
+		if string(magic[:3]) == "ID3" {
+			decoder, err = newFLACDecoder(file, reader)
+		} else {
+			err = fmt.Errorf("unsupported file format (expected WAV or FLAC)")
+		}
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return decoder, nil
+}
+
+// wavDecoder streams PCM or float samples from a RIFF/WAVE file
+type wavDecoder struct {
+	file      *os.File
+	reader    *bufio.Reader
+	rate      float64
+	numChans  int
+	frameSize int
+	decode    func([]byte) float32
+	remaining int64 // Bytes left in the data chunk, -1 if unknown
+	frame     []byte
+}
+
+// newWAVDecoder parses the WAV headers up to the start of the sample data
+func newWAVDecoder(file *os.File, reader *bufio.Reader) (*wavDecoder, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a WAVE file")
+	}
+
+	d := &wavDecoder{file: file, reader: reader}
+	haveFormat := false
+
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(reader, chunkHeader); err != nil {
+			return nil, fmt.Errorf("no data chunk found")
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch chunkID {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, fmt.Errorf("invalid fmt chunk")
+			}
+			format := make([]byte, chunkSize)
+			if _, err := io.ReadFull(reader, format); err != nil {
+				return nil, err
+			}
+
+			formatTag := binary.LittleEndian.Uint16(format[0:2])
+			d.numChans = int(binary.LittleEndian.Uint16(format[2:4]))
+			d.rate = float64(binary.LittleEndian.Uint32(format[4:8]))
+			d.frameSize = int(binary.LittleEndian.Uint16(format[12:14]))
+			bitsPerSample := int(binary.LittleEndian.Uint16(format[14:16]))
+
+			// WAVE_FORMAT_EXTENSIBLE keeps the real format in the sub-format GUID
+			if formatTag == wavFormatExtensible && chunkSize >= 26 {
+				formatTag = binary.LittleEndian.Uint16(format[24:26])
+			}
+
+			if formatTag != wavFormatPCM && formatTag != wavFormatFloat {
+				return nil, fmt.Errorf("unsupported WAV encoding %d", formatTag)
+			}
+			decode, err := sampleDecoder(formatTag == wavFormatFloat, bitsPerSample)
+			if err != nil {
+				return nil, err
+			}
+			if d.numChans < 1 || d.frameSize < d.numChans*bitsPerSample/8 {
+				return nil, fmt.Errorf("invalid WAV channel layout")
+			}
+
+			d.decode = decode
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("data chunk before fmt chunk")
+			}
+
+			// Streamed WAVs may leave the size at 0 or max
+			d.remaining = chunkSize
+			if chunkSize == 0 || chunkSize == 0xFFFFFFFF {
+				d.remaining = -1
+			}
+			d.frame = make([]byte, d.frameSize)
+			return d, nil
+
+		default:
+			if _, err := reader.Discard(int(chunkSize)); err != nil {
+				return nil, err
+			}
+		}
+
+		// Chunks are padded to an even size
+		if chunkSize%2 == 1 {
+			reader.Discard(1)
+		}
+	}
+}
+
+// read decodes interleaved frames into the per-channel buffer
+func (d *wavDecoder) read(buffer [][]float32) (int, error) {
+	frames := len(buffer[0])
+	bytesPerSample := d.frameSize / d.numChans
+
+	for i := 0; i < frames; i++ {
+		if d.remaining >= 0 && d.remaining < int64(d.frameSize) {
+			return i, io.EOF
+		}
+		if _, err := io.ReadFull(d.reader, d.frame); err != nil {
+			return i, io.EOF
+		}
+		if d.remaining >= 0 {
+			d.remaining -= int64(d.frameSize)
+		}
+
+		for ch := range buffer {
+			// Map extra output channels onto the last file channel
+			src := ch
+			if src >= d.numChans {
+				src = d.numChans - 1
+			}
+			buffer[ch][i] = d.decode(d.frame[src*bytesPerSample:])
+		}
+	}
+
+	return frames, nil
+}
+
+func (d *wavDecoder) sampleRate() float64 { return d.rate }
+func (d *wavDecoder) channels() int       { return d.numChans }
+func (d *wavDecoder) close() error        { return d.file.Close() }
+
+// sampleDecoder returns a function converting one little-endian sample to float32
+func sampleDecoder(float bool, bits int) (func([]byte) float32, error) {
+	if float {
+		switch bits {
+		case 32:
+			return func(b []byte) float32 {
+				return math.Float32frombits(binary.LittleEndian.Uint32(b))
+			}, nil
+		case 64:
+			return func(b []byte) float32 {
+				return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+			}, nil
+		}
+		return nil, fmt.Errorf("unsupported float sample size %d", bits)
+	}
+
+	switch bits {
+	case 8:
+		// 8-bit PCM is unsigned
+		return func(b []byte) float32 {
+			return float32(int(b[0])-128) / 128
+		}, nil
+	case 16:
+		return func(b []byte) float32 {
+			return float32(int16(binary.LittleEndian.Uint16(b))) / 32768
+		}, nil
+	case 24:
+		return func(b []byte) float32 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float32(v) / 8388608
+		}, nil
+	case 32:
+		return func(b []byte) float32 {
+			return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported PCM sample size %d", bits)
+}
@@ -0,0 +1,136 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// wavHeaderSize is the size of the RIFF, fmt (with cbSize), fact and data chunk headers
+const wavHeaderSize = 12 + 8 + 18 + 8 + 4 + 8
+
+// Largest data chunk whose RIFF size still fits the 32-bit header field
+var maxWAVDataSize int64 = math.MaxUint32 - (wavHeaderSize - 8)
+
+// WAVWriter writes 32-bit float WAV files. The sizes in the header are patched on
+// Close; a file that was never closed reads as a stream of unknown length
+type WAVWriter struct {
+	file       *os.File
+	writer     *bufio.Writer
+	sampleRate float64
+	channels   int
+	frames     int64
+	sample     []byte
+}
+
+// NewWAVWriter creates the file and writes a provisional header
+func NewWAVWriter(path string, sampleRate float64, channels int) (*WAVWriter, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("invalid channel count %d", channels)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+
+	w := &WAVWriter{
+		file:       file,
+		writer:     bufio.NewWriterSize(file, 64*1024),
+		sampleRate: sampleRate,
+		channels:   channels,
+		sample:     make([]byte, 4),
+	}
+	if _, err := w.writer.Write(w.header()); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return w, nil
+}
+
+// header returns the WAV header for the frames written so far
+func (w *WAVWriter) header() []byte {
+	blockAlign := w.channels * 4
+	dataSize := uint32(w.frames * int64(blockAlign))
+	rate := uint32(math.Round(w.sampleRate))
+
+	header := make([]byte, 0, wavHeaderSize)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(wavHeaderSize-8)+dataSize)
+	header = append(header, "WAVE"...)
+
+	header = append(header, "fmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 18)
+	header = binary.LittleEndian.AppendUint16(header, wavFormatFloat)
+	header = binary.LittleEndian.AppendUint16(header, uint16(w.channels))
+	header = binary.LittleEndian.AppendUint32(header, rate)
+	header = binary.LittleEndian.AppendUint32(header, rate*uint32(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, 32)
+	header = binary.LittleEndian.AppendUint16(header, 0) // No extension
+
+	// Float formats carry the frame count in a fact chunk
+	header = append(header, "fact"...)
+	header = binary.LittleEndian.AppendUint32(header, 4)
+	header = binary.LittleEndian.AppendUint32(header, uint32(w.frames))
+
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, dataSize)
+	return header
+}
+
+// Fits returns whether frames more frames keep the file within the 4 GiB WAV limit
+func (w *WAVWriter) Fits(frames int) bool {
+	return (w.frames+int64(frames))*int64(w.channels*4) <= maxWAVDataSize
+}
+
+// Write appends a buffer (one slice per channel); missing channels are written as silence.
+// A buffer that would take the file past the 4 GiB WAV limit is refused
+func (w *WAVWriter) Write(buffer [][]float32) error {
+	if len(buffer) == 0 {
+		return nil
+	}
+	if !w.Fits(len(buffer[0])) {
+		return fmt.Errorf("WAV file would exceed 4 GiB")
+	}
+
+	for i := range buffer[0] {
+		for ch := 0; ch < w.channels; ch++ {
+			sample := float32(0)
+			if ch < len(buffer) && i < len(buffer[ch]) {
+				sample = buffer[ch][i]
+			}
+			binary.LittleEndian.PutUint32(w.sample, math.Float32bits(sample))
+			if _, err := w.writer.Write(w.sample); err != nil {
+				return fmt.Errorf("failed to write audio: %v", err)
+			}
+		}
+	}
+	w.frames += int64(len(buffer[0]))
+	return nil
+}
+
+// Frames returns the number of frames written
+func (w *WAVWriter) Frames() int64 {
+	return w.frames
+}
+
+// Close flushes the samples, patches the header sizes and closes the file
+func (w *WAVWriter) Close() error {
+	err := w.writer.Flush()
+	if err == nil {
+		if _, err = w.file.Seek(0, io.SeekStart); err == nil {
+			_, err = w.file.Write(w.header())
+		}
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to finish WAV file: %v", err)
+	}
+	return nil
+}
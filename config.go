@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// config holds settings read from the user config directory; flags override them
+type config struct {
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	BufferSize int     `json:"buffer_size,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+	LastDevice string  `json:"last_device,omitempty"`
+	AVOffset   int     `json:"av_offset_ms,omitempty"`
+}
+
+// configPath returns the location of the config file, e.g. ~/.config/milkshaker/config.json
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "milkshaker", "config.json"), nil
+}
+
+// loadConfig reads the config file; a missing file yields the defaults
+func loadConfig() (config, error) {
+	var cfg config
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return config{}, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config file, creating its directory if needed
+func saveConfig(cfg config) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	return nil
+}
+
+// rememberDevice stores the last used capture device in the config file
+func rememberDevice(name string) {
+	cfg, err := loadConfig()
+	if err != nil || cfg.LastDevice == name {
+		// Never overwrite a config file we could not parse
+		return
+	}
+	cfg.LastDevice = name
+	saveConfig(cfg)
+}
+
+// rememberAVOffset stores the A/V offset in the config file
+func rememberAVOffset(offset time.Duration) {
+	cfg, err := loadConfig()
+	if err != nil || cfg.AVOffset == int(offset.Milliseconds()) {
+		return
+	}
+	cfg.AVOffset = int(offset.Milliseconds())
+	saveConfig(cfg)
+}
+
+// loadConfigOrDefaults reads the config file, warning and falling back to defaults on errors
+func loadConfigOrDefaults() config {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring config file: %v\n", err)
+	}
+	return cfg
+}
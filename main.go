@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"milkshaker/audio"
@@ -20,27 +23,42 @@ func main() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "devices":
-			listAudioDevices()
+			listAudioDevices(os.Args[2:])
 			return
 		case "setup-audio":
 			setupSystemAudio()
 			return
 		case "test-audio":
-			testAudioCapture()
+			testAudioCapture(os.Args[2:])
 			return
 		case "test-monitor":
 			testMonitorSource()
 			return
+		case "self-test":
+			if !audio.NewTester().SelfTest() {
+				os.Exit(1)
+			}
+			return
+		case "play":
+			playFile(os.Args[2:])
+			return
+		case "replay":
+			replaySession(os.Args[2:])
+			return
 
 		case "help":
 			showHelp()
 			return
 		}
 	}
-	AudioPlayerMain()
+	AudioPlayerMain(os.Args[1:])
 }
 
-func listAudioDevices() {
+func listAudioDevices(args []string) {
+	fs := flag.NewFlagSet("devices", flag.ExitOnError)
+	stream := registerStreamOptions(fs, loadConfigOrDefaults())
+	fs.Parse(args)
+
 	err := portaudio.Initialize()
 	if err != nil {
 		log.Fatalf("Failed to initialize PortAudio: %v", err)
@@ -62,6 +80,11 @@ func listAudioDevices() {
 		fmt.Printf("    Default Sample Rate: %.0f Hz\n", device.DefaultSampleRate)
 		if device.MaxInputChannels > 0 {
 			fmt.Printf("    Input Latency: %.3f ms\n", device.DefaultLowInputLatency.Seconds()*1000)
+			if negotiated, err := audio.NegotiateStreamConfig(device, stream.streamConfig()); err == nil {
+				fmt.Printf("    Capture Format: %s\n", formatStreamConfig(negotiated))
+			} else {
+				fmt.Printf("    Capture Format: unsupported (%v)\n", err)
+			}
 		}
 		if device.MaxOutputChannels > 0 {
 			fmt.Printf("    Output Latency: %.3f ms\n", device.DefaultLowOutputLatency.Seconds()*1000)
@@ -104,8 +127,20 @@ func setupSystemAudio() {
 	fmt.Println("After setup, run the visualizer and play some music to test!")
 }
 
-func testAudioCapture() {
+func testAudioCapture(args []string) {
+	fs := flag.NewFlagSet("test-audio", flag.ExitOnError)
+	generate := fs.String("generate", "", "test with a synthetic signal: sweep, pink, kick or silence")
+	bpm := fs.Float64("bpm", 120, "tempo of the generated kick drum")
+	fs.Parse(args)
+
 	tester := audio.NewTester()
+	if *generate != "" {
+		source, err := audio.NewGeneratorSource(audio.Signal(*generate), *bpm)
+		if err != nil {
+			log.Fatalf("Failed to create generator: %v", err)
+		}
+		tester = audio.NewTesterWithSource(source)
+	}
 	tester.Run()
 }
 
@@ -115,14 +150,25 @@ func testMonitorSource() {
 	fmt.Println("This will test if the monitor source is properly configured.")
 	fmt.Println()
 
+	// Rule out analysis problems before blaming the monitor source
+	if !audio.NewTester().SelfTest() {
+		fmt.Println("❌ Audio levels below may be misleading until the analysis chain is fixed")
+	}
+	fmt.Println()
+
 	player := audio.NewPlayer()
 
 	// Initialize but don't start the full visualizer
 	if err := player.Initialize(); err != nil {
+		cleanup(player)
 		fmt.Printf("❌ Failed to initialize: %v\n", err)
 		return
 	}
-	defer player.Cleanup()
+	defer cleanup(player)
+
+	// Stop on Ctrl+C so the deferred Cleanup restores the routing
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT)
 
 	fmt.Println("\nTesting audio capture from configured source...")
 	fmt.Println("Play some music and you should see audio levels below:")
@@ -137,7 +183,12 @@ func testMonitorSource() {
 
 	// Monitor for a bit
 	for i := 0; i < 100; i++ {
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-signals:
+			fmt.Println("\nTest interrupted")
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
 		peak := player.GetPeakLevel()
 
 		if peak > 0.01 {
@@ -165,22 +216,168 @@ func showHelp() {
 	fmt.Println("  go run . setup-audio     # Show audio setup instructions")
 	fmt.Println("  go run . test-audio      # Test audio capture without UI")
 	fmt.Println("  go run . test-monitor    # Test monitor source configuration")
+	fmt.Println("  go run . play <file>     # Visualize a WAV/FLAC file (add --loop to repeat)")
+	fmt.Println("  go run . self-test       # Check the analysis chain with generated signals")
+	fmt.Println("  go run . replay <log>    # Replay a recorded feature log (add --headless for CI)")
 	fmt.Println("  go run . help            # Show this help")
 	fmt.Println()
+	fmt.Println("Raw PCM input (e.g. from parec, ffmpeg or an MPD fifo):")
+	fmt.Println("  parec --format=s16le | go run . --pcm -")
+	fmt.Println("  go run . --pcm /tmp/mpd.fifo --pcm-rate 44100 --pcm-channels 2")
+	fmt.Println()
+	fmt.Println("Capture format (also read from the config file, see README):")
+	fmt.Println("  go run . --rate 48000 --buffer 256 --channels 2")
+	fmt.Println("  go run . devices --rate 48000   # Show what each device negotiates")
+	fmt.Println("  go run . --device 3              # Capture from device [3] (or --device pulse)")
+	fmt.Println()
+	fmt.Println("Synthetic test signals (no audio hardware needed):")
+	fmt.Println("  go run . --generate sweep|pink|kick|silence [--bpm 120]")
+	fmt.Println("  go run . test-audio --generate kick --bpm 128")
+	fmt.Println()
+	fmt.Println("Recording (works with any input):")
+	fmt.Println("  go run . --record session.wav --record-features session.jsonl")
+	fmt.Println("  go run . replay session.jsonl --headless --snapshot frame.txt")
+	fmt.Println()
+	fmt.Println("Audio/visual sync (also adjustable with [ and ], remembered in the config file):")
+	fmt.Println("  go run . --av-offset 200         # Delay the visuals 200 ms for Bluetooth speakers")
+	fmt.Println()
+	fmt.Println("Sound server routing (PulseAudio/PipeWire, undone on exit):")
+	fmt.Println("  go run . --app spotify           # Capture only one application")
+	fmt.Println("  go run . --no-routing            # Never touch the system routing")
+	fmt.Println()
 	fmt.Println("For system audio capture on Linux:")
 	fmt.Println("  Run: go run . setup-audio")
 }
 
-func AudioPlayerMain() {
-	player := audio.NewPlayer()
+// parseArgs parses flags that may appear before or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// usageError reports a command line mistake, shows the usage and exits
+func usageError(fs *flag.FlagSet, err error) {
+	fmt.Printf("Error: %v\n\n", err)
+	fs.Usage()
+	os.Exit(1)
+}
+
+func playFile(args []string) {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: go run . play <file.wav|file.flac> [--loop]")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+	loop := fs.Bool("loop", false, "restart the file when it ends")
+	opts := registerOptions(fs)
+	files := parseArgs(fs, args)
+
+	if len(files) != 1 {
+		usageError(fs, fmt.Errorf("expected one file, got %d", len(files)))
+	}
+	if err := checkFlags(fs, inputFile); err != nil {
+		usageError(fs, err)
+	}
+
+	player := audio.NewPlayerWithSource(audio.NewFileSource(files[0], *loop))
+	if err := opts.configure(player); err != nil {
+		log.Fatalf("Failed to create audio player: %v", err)
+	}
+	runVisualizer(player)
+}
+
+func AudioPlayerMain(args []string) {
+	fs := flag.NewFlagSet("milkshaker", flag.ExitOnError)
+	fs.Usage = func() {
+		showHelp()
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+	opts := registerOptions(fs)
+	if extra := parseArgs(fs, args); len(extra) > 0 {
+		usageError(fs, fmt.Errorf("unknown command or argument %q", extra[0]))
+	}
+	input, err := opts.input()
+	if err == nil {
+		err = checkFlags(fs, input)
+	}
+	if err != nil {
+		usageError(fs, err)
+	}
+
+	player, err := opts.newPlayer()
+	if err != nil {
+		log.Fatalf("Failed to create audio player: %v", err)
+	}
+	runVisualizer(player)
+}
+
+// levelUnit selects how the status line displays audio levels
+type levelUnit int
+
+const (
+	unitPercent levelUnit = iota // Linear peak after gain
+	unitDBFS                     // Peak and RMS in dBFS before gain
+	unitLUFS                     // Short-term loudness before gain
+	levelUnitCount
+)
+
+// formatLevel renders the current level in the selected unit
+func formatLevel(player *audio.Player, unit levelUnit) string {
+	switch unit {
+	case unitDBFS:
+		return fmt.Sprintf("Peak: %s dBFS | RMS: %s dBFS", formatDB(player.GetPeakDBFS()), formatDB(player.GetRMSDBFS()))
+	case unitLUFS:
+		return fmt.Sprintf("Loudness: %s LUFS (3s)", formatDB(player.GetLoudnessLUFS()))
+	}
+	return fmt.Sprintf("Peak: %.0f%%", player.GetVolumePercentage())
+}
+
+// formatStreamConfig renders a capture format, e.g. "48000 Hz, 2 ch, 256 frames"
+func formatStreamConfig(config audio.StreamConfig) string {
+	return fmt.Sprintf("%.0f Hz, %d ch, %d frames", config.SampleRate, config.Channels, config.FramesPerBuffer)
+}
 
+// formatDB renders a decibel value, showing silence as -inf
+func formatDB(db float64) string {
+	if db <= audio.SilenceDB {
+		return "-inf"
+	}
+	return fmt.Sprintf("%.1f", db)
+}
+
+// A/V offset change per [ or ] key press
+const avOffsetStep = 10 * time.Millisecond
+
+// cleanup releases the player and reports a recording that could not be finished
+func cleanup(player *audio.Player) {
+	if err := player.Cleanup(); err != nil {
+		log.Printf("Recording incomplete: %v", err)
+	}
+}
+
+func runVisualizer(player *audio.Player) {
+	// log.Fatalf skips deferred calls; clean up first so routing changes are undone
 	if err := player.Initialize(); err != nil {
+		cleanup(player)
 		log.Fatalf("Failed to initialize audio player: %v", err)
 	}
-	defer player.Cleanup()
+	defer cleanup(player)
 
-	// Start audio capture automatically
-	if err := player.Start(); err != nil {
+	// Start audio capture automatically; a device that fails to start is retried by the
+	// watchdog while the status line shows it waiting
+	if err := player.Start(); err != nil && !player.IsCapturing() {
+		cleanup(player)
 		log.Fatalf("Failed to start audio capture: %v", err)
 	}
 
@@ -200,34 +397,97 @@ func AudioPlayerMain() {
 		SetDynamicColors(true).
 		SetTextAlign(tview.AlignCenter)
 
+	unit := unitPercent
+
+	// Why the last application capture failed; only touched on the UI goroutine
+	appError := ""
+
 	updateInfo := func() {
 		shuffleStatus := ""
 		if patternManager.IsShuffleEnabled() {
 			shuffleStatus = ""
 		}
-		infoTextNowPlaying.SetText(fmt.Sprintf("Peak: %.0f%% | Sensitivity: %.1fx | Device: %s%s", player.GetVolumePercentage(), player.GetSensitivity(), player.GetCurrentDeviceName(), shuffleStatus))
+		tempoStatus := ""
+		if bpm := player.GetTempo(); bpm > 0 {
+			tempoStatus = fmt.Sprintf(" | %.0f BPM", bpm)
+		}
+		if key := player.GetKey(); key.Confidence >= 0.6 {
+			tempoStatus += fmt.Sprintf(" | Key: %s", key)
+		}
+		if patternManager.IsNoteHueEnabled() {
+			if pitchClass := player.GetPitchClass(); pitchClass >= 0 {
+				tempoStatus += fmt.Sprintf(" | Note: %s", audio.NoteNames[pitchClass])
+			}
+		}
+		gainStatus := fmt.Sprintf("AGC: OFF | Sensitivity: %.1fx", player.GetSensitivity())
+		if player.IsAGCEnabled() {
+			gainStatus = fmt.Sprintf("AGC: ON | Trim: %.1fx", player.GetSensitivity())
+		}
+		appStatus := ""
+		if app := player.GetApp(); app != "" {
+			appStatus = fmt.Sprintf(" | App: %s", app)
+		}
+		if appError != "" {
+			appStatus += fmt.Sprintf(" | App capture failed: %s", appError)
+		}
+		streamStatus := ""
+		if player.IsRecording() {
+			streamStatus = " | REC"
+			if dropped := player.GetDroppedBuffers(); dropped > 0 {
+				streamStatus += fmt.Sprintf(" (%d dropped)", dropped)
+			}
+			if err := player.GetRecordingError(); err != nil {
+				streamStatus += fmt.Sprintf(" stopped: %v", err)
+			}
+		}
+		switch state := player.GetStreamState(); state {
+		case audio.StreamStalled, audio.StreamRecovering, audio.StreamFinished:
+			streamStatus += fmt.Sprintf(" | Stream: %s", state)
+		case audio.StreamFailed:
+			streamStatus += " | Stream: waiting for device"
+		}
+		if err := player.GetSourceError(); err != nil {
+			streamStatus += fmt.Sprintf(" | Error: %v", err)
+		}
+		syncStatus := ""
+		if latency := player.GetInputLatency(); latency > 0 {
+			syncStatus = fmt.Sprintf(" | Latency: %d ms", latency.Milliseconds())
+		}
+		if offset := player.GetAVOffset(); offset != 0 {
+			syncStatus += fmt.Sprintf(" | A/V: %+d ms", offset.Milliseconds())
+		}
+		infoTextNowPlaying.SetText(fmt.Sprintf("%s | %s%s | Device: %s (%s)%s%s%s%s", formatLevel(player, unit), gainStatus, tempoStatus, player.GetCurrentDeviceName(), formatStreamConfig(player.GetStreamConfig()), syncStatus, appStatus, streamStatus, shuffleStatus))
 		visualizerName := patternManager.GetCurrentVisualizatorName()
 		if patternManager.IsShuffleEnabled() {
 			visualizerName = "SHUFFLE"
 		}
+		if patternManager.IsIdle() {
+			visualizerName = "IDLE (waiting for audio)"
+		}
 		infoTextVolume.SetText(fmt.Sprintf("Visualizator: %s (%d/%d)", visualizerName, patternManager.GetCurrentVisualizatorIndex()+1, patternManager.GetVisualizatorCount()))
 	}
 
 	player.SetUpdateInfoFunc(updateInfo)
 	fullScreenVisualizer := tview.NewBox().SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
-		// Get current audio peak
-		peak := player.GetPeakLevel()
+		// Get current audio features
+		frame := player.GetAudioFrame()
+
+		// Fall back to the calm idle screen while nothing is playing
+		patternManager.SetIdle(frame.Silent)
+
+		// Keep the level readout live, not just refreshed on key presses
+		updateInfo()
 
 		// Create RNG for patterns
 		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 		// Draw current visualizator patterns
-		patternManager.DrawCurrentVisualizator(screen, tcell.ColorWhite, rng, peak)
+		patternManager.DrawCurrentVisualizator(screen, tcell.ColorWhite, rng, frame)
 
 		tview.Print(screen, infoTextNowPlaying.GetText(true), x, y, width, tview.AlignCenter, tcell.ColorWhite)
 		tview.Print(screen, infoTextVolume.GetText(true), x, y+1, width, tview.AlignCenter, tcell.ColorWhite)
 
-		statusText := "+/- (Sensitivity) | D (Device) | P (Patterns) | X (Shuffle) | Ctrl+C (Quit)"
+		statusText := "+/- (Sensitivity) | A (AGC) | U (Units) | N (Note colors) | [/] (A/V offset) | D (Device) | C (App) | P (Patterns) | X (Shuffle) | Ctrl+C (Quit)"
 		tview.Print(screen, statusText, x, height-1, width, tview.AlignCenter, tcell.ColorGreenYellow)
 
 		return x, y, width, height
@@ -238,10 +498,23 @@ func AudioPlayerMain() {
 	go func() {
 		for {
 			time.Sleep(time.Second / 60) // 60 FPS
+			// A file played without --loop ends the session
+			if player.IsFinished() {
+				app.Stop()
+				return
+			}
 			app.Draw()
 		}
 	}()
 
+	// Quit cleanly when the terminal closes or we are killed, so Cleanup restores the routing
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGINT)
+	go func() {
+		<-signals
+		app.Stop()
+	}()
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Rune() {
 		case 'r', 'R':
@@ -250,14 +523,41 @@ func AudioPlayerMain() {
 			player.IncreaseSensitivity()
 		case '-', '_':
 			player.DecreaseSensitivity()
+		case 'a', 'A':
+			// Toggle automatic gain control; sensitivity stays as a trim on top
+			player.ToggleAGC()
+		case 'u', 'U':
+			// Cycle level units: percent, dBFS, LUFS
+			unit = (unit + 1) % levelUnitCount
+		case 'n', 'N':
+			// Toggle pattern colors following the note being played
+			patternManager.ToggleNoteHue()
+		case '[':
+			// Show the visuals earlier or later to line up with what is heard
+			player.AdjustAVOffset(-avOffsetStep)
+		case ']':
+			player.AdjustAVOffset(avOffsetStep)
 		case 'd', 'D':
 			// Cycle to next audio input device
 			player.CycleDevice()
+		case 'c', 'C':
+			// Cycle capturing a single playing application, then the whole output again;
+			// pactl is slow, so keep it off the UI goroutine
+			go func() {
+				_, err := player.CycleApp()
+				app.QueueUpdateDraw(func() {
+					appError = ""
+					if err != nil {
+						appError = err.Error()
+					}
+					updateInfo()
+				})
+			}()
 		case 'p', 'P':
 			// Cycle to next visualizator
 			patternManager.CycleVisualizator()
 		case 'x', 'X':
-			// Toggle shuffle mode (cycles visualizators every 27 seconds)
+			// Toggle shuffle mode (cycles visualizators every 27 seconds, on a bar boundary when the tempo is known)
 			patternManager.ToggleShuffle()
 		}
 
@@ -273,4 +573,12 @@ func AudioPlayerMain() {
 	if err := app.SetRoot(fullScreenVisualizer, true).SetFocus(fullScreenVisualizer).Run(); err != nil {
 		fmt.Printf("\nVisualizer stopped: %v\n", err)
 	}
+	if player.IsFinished() {
+		if err := player.GetSourceError(); err != nil {
+			fmt.Printf("Playback stopped: %v\n", err)
+		} else {
+			fmt.Printf("Finished playing %s\n", player.GetCurrentDeviceName())
+		}
+	}
+	rememberAVOffset(player.GetAVOffset())
 }
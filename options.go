@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"milkshaker/audio"
+)
+
+// Inputs the visualizer can be driven from, as named in error messages
+const (
+	inputDevice    = "a capture device"
+	inputPCM       = "raw PCM input"
+	inputGenerator = "a generated signal"
+	inputFile      = "file playback"
+)
+
+// inputFlags maps the flags that only have an effect on one input to that input
+var inputFlags = map[string]string{
+	"device":       inputDevice,
+	"app":          inputDevice,
+	"no-routing":   inputDevice,
+	"rate":         inputDevice,
+	"buffer":       inputDevice,
+	"channels":     inputDevice,
+	"pcm":          inputPCM,
+	"pcm-format":   inputPCM,
+	"pcm-rate":     inputPCM,
+	"pcm-channels": inputPCM,
+	"generate":     inputGenerator,
+	"bpm":          inputGenerator,
+}
+
+// options holds the command line settings shared by the visualizer commands
+type options struct {
+	pcmInput    string
+	pcmFormat   string
+	pcmRate     float64
+	pcmChannels int
+	generate    string
+	bpm         float64
+
+	gate           float64
+	gateHysteresis float64
+	idleAfter      time.Duration
+	avOffset       int // Milliseconds
+
+	stream     *streamOptions
+	device     string
+	lastDevice string
+	noRouting  bool
+	app        string
+
+	record         string
+	recordFeatures string
+}
+
+// streamOptions holds the capture format requested from PortAudio devices
+type streamOptions struct {
+	rate     float64
+	buffer   int
+	channels int
+}
+
+// registerStreamOptions adds the capture format flags, defaulting to the config file values
+func registerStreamOptions(fs *flag.FlagSet, cfg config) *streamOptions {
+	s := &streamOptions{}
+	fs.Float64Var(&s.rate, "rate", cfg.SampleRate, "capture sample rate in Hz (0 negotiates: device default, then 44100)")
+	fs.IntVar(&s.buffer, "buffer", cfg.BufferSize, "frames per capture buffer (0 = 1024; smaller lowers latency)")
+	fs.IntVar(&s.channels, "channels", cfg.Channels, "capture channel count (0 = stereo, or mono on single-channel devices)")
+	return s
+}
+
+// streamConfig returns the requested capture format
+func (s *streamOptions) streamConfig() audio.StreamConfig {
+	return audio.StreamConfig{
+		SampleRate:      s.rate,
+		FramesPerBuffer: s.buffer,
+		Channels:        s.channels,
+	}
+}
+
+// registerOptions adds the shared visualizer flags to a flag set
+func registerOptions(fs *flag.FlagSet) *options {
+	o := &options{}
+	cfg := loadConfigOrDefaults()
+	o.stream = registerStreamOptions(fs, cfg)
+	o.lastDevice = cfg.LastDevice
+	fs.StringVar(&o.device, "device", "", "capture device index or name substring (see the devices command); defaults to the last used device")
+	fs.StringVar(&o.app, "app", "", "capture only the application whose name contains this (e.g. spotify) instead of the whole output")
+	fs.BoolVar(&o.noRouting, "no-routing", false, "never change the default source, move recording streams or load modules")
+	fs.StringVar(&o.record, "record", "", "record the captured audio to this WAV file")
+	fs.StringVar(&o.recordFeatures, "record-features", "", "log the analysis of every buffer to this NDJSON file (see the replay command)")
+	fs.StringVar(&o.pcmInput, "pcm", "", "read raw interleaved PCM from a FIFO/file path, or - for stdin")
+	fs.StringVar(&o.pcmFormat, "pcm-format", string(audio.PCMS16LE), "raw PCM sample format: s16le or f32le")
+	fs.Float64Var(&o.pcmRate, "pcm-rate", 44100, "raw PCM sample rate in Hz")
+	fs.IntVar(&o.pcmChannels, "pcm-channels", 2, "raw PCM channel count")
+	fs.StringVar(&o.generate, "generate", "", "visualize a synthetic signal: sweep, pink, kick or silence")
+	fs.Float64Var(&o.bpm, "bpm", 120, "tempo of the generated kick drum")
+	fs.Float64Var(&o.gate, "gate", -60, "noise gate threshold in dBFS RMS (-120 disables the gate)")
+	fs.Float64Var(&o.gateHysteresis, "gate-hysteresis", 6, "dB below the gate threshold at which the gate closes again")
+	fs.DurationVar(&o.idleAfter, "idle-after", 10*time.Second, "switch to the idle screen after this much silence (0 disables)")
+	fs.IntVar(&o.avOffset, "av-offset", cfg.AVOffset, "delay the visuals by this many ms to match slow speakers (e.g. 200 for Bluetooth; negative runs the beat phase ahead)")
+	return o
+}
+
+// newPlayer creates a player for the selected input
+func (o *options) newPlayer() (*audio.Player, error) {
+	source, err := o.newSource()
+	if err != nil {
+		return nil, err
+	}
+	player := audio.NewPlayer()
+	if source != nil {
+		player = audio.NewPlayerWithSource(source)
+	}
+	if err := o.configure(player); err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// configure applies the analysis and recording settings to a player
+func (o *options) configure(player *audio.Player) error {
+	player.SetStreamConfig(o.stream.streamConfig())
+	player.SetDevice(o.device)
+	player.SetLastDevice(o.lastDevice)
+	player.SetDeviceChangedFunc(rememberDevice)
+	player.SetRoutingEnabled(!o.noRouting)
+	player.SetApp(o.app)
+	player.SetGateThreshold(o.gate, o.gateHysteresis)
+	player.SetIdleAfter(o.idleAfter)
+	player.SetAVOffset(time.Duration(o.avOffset) * time.Millisecond)
+	return player.StartRecording(o.record, o.recordFeatures)
+}
+
+// input returns the input selected by the flags
+func (o *options) input() (string, error) {
+	switch {
+	case o.generate != "" && o.pcmInput != "":
+		return "", fmt.Errorf("--generate and --pcm select different inputs; use one of them")
+	case o.generate != "":
+		return inputGenerator, nil
+	case o.pcmInput != "":
+		return inputPCM, nil
+	}
+	return inputDevice, nil
+}
+
+// checkFlags rejects flags given on the command line that have no effect on the input
+func checkFlags(fs *flag.FlagSet, input string) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		if applies, ok := inputFlags[f.Name]; ok && applies != input && err == nil {
+			err = fmt.Errorf("--%s has no effect with %s; it only applies to %s", f.Name, input, applies)
+		}
+	})
+	return err
+}
+
+// newSource creates the source selected by the flags, or nil for the default capture device
+func (o *options) newSource() (audio.Source, error) {
+	switch {
+	case o.generate != "":
+		return audio.NewGeneratorSource(audio.Signal(o.generate), o.bpm)
+	case o.pcmInput != "":
+		return audio.NewPCMSource(o.pcmInput, audio.PCMFormat(o.pcmFormat), o.pcmRate, o.pcmChannels)
+	}
+	return nil, nil
+}
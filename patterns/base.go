@@ -1,16 +1,56 @@
 package patterns
 
 import (
+	"math"
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
 // Common utilities and types for all patterns
 
-// HSVToRGB converts HSV color values to RGB tcell.Color
+// Tempo shared with patterns, updated by the manager every frame
+var (
+	tempoBPM  float64
+	beatPhase float64
+)
+
+// Optional note-driven coloring, updated by the manager every frame
+var (
+	noteHueEnabled bool
+	noteHue        = -1.0 // Hue of the current note, -1 when none
+)
+
+// clock is the time source for all animations; replays drive it from the recorded timeline
+var clock = time.Now
+
+// SetClock replaces the time source used by patterns; nil restores the wall clock
+func SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	clock = now
+}
+
+// How much of a pattern's own hue variation survives when colors follow the note
+const noteHueSpread = 0.15
+
+// NoteHue returns the hue for a pitch class (0 = C); notes a fifth apart get neighbouring hues
+func NoteHue(pitchClass int) float64 {
+	return float64((pitchClass*7)%12) / 12
+}
+
+// HSVToRGB converts HSV color values to RGB tcell.Color.
+// When note colors are enabled the hue is pulled toward the current note's hue
 func HSVToRGB(h, s, v float64) tcell.Color {
+	h -= math.Floor(h)
+	if noteHueEnabled && noteHue >= 0 {
+		h = math.Mod(noteHue+h*noteHueSpread, 1.0)
+	}
+
 	i := int(h * 6)
 	f := h*6 - float64(i)
 	p := v * (1 - s)
@@ -54,7 +94,12 @@ func Min(a, b int) int {
 
 // GetBasePhase returns current time-based phase for animations
 func GetBasePhase() float64 {
-	return float64(time.Now().UnixNano()) / 1e9
+	return float64(clock().UnixNano()) / 1e9
+}
+
+// GetBeatPhase returns the position within the current beat (0.0 on the beat) and whether a tempo is locked
+func GetBeatPhase() (float64, bool) {
+	return beatPhase, tempoBPM > 0
 }
 
 // RandomRune returns a random character from a predefined set
@@ -63,5 +108,5 @@ func RandomRune(rng *rand.Rand) rune {
 	return runes[rng.Intn(len(runes))]
 }
 
-// PatternFunc defines the signature for pattern drawing functions
-type PatternFunc func(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64)
+// FramePatternFunc defines the signature for pattern drawing functions that receive all audio features
+type FramePatternFunc func(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame)
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -71,41 +73,24 @@ var (
 	mathPhase     float64 = 0.0
 	fibLastUpdate time.Time
 
-	// Peak tracking for mathematical beauty
-	fibPeakHistory []float64
-	maxFibHistory  = 25
-
 	// Golden ratio constant
 	goldenRatio = (1 + math.Sqrt(5)) / 2
 	goldenAngle = 2 * math.Pi / (goldenRatio * goldenRatio)
 )
 
 // DrawFibonacci creates an epic mathematical fibonacci visualization with sacred geometry
-func DrawFibonacci(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64) {
-	now := time.Now()
+func DrawFibonacci(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame) {
+	now := clock()
 	elapsed := now.Sub(fibLastUpdate).Seconds()
 	if elapsed < 1.0/180.0 { // 180 FPS limit
 		return
 	}
 	fibLastUpdate = now
 
-	// Track peak history for mathematical progression
-	fibPeakHistory = append(fibPeakHistory, peak)
-	if len(fibPeakHistory) > maxFibHistory {
-		fibPeakHistory = fibPeakHistory[1:]
-	}
+	peak := frame.Peak
 
-	// Calculate mathematical progression
-	mathProgression := 0.0
-	if len(fibPeakHistory) > 10 {
-		recent := fibPeakHistory[len(fibPeakHistory)-5:]
-		avgRecent := 0.0
-		for _, p := range recent {
-			avgRecent += p
-		}
-		avgRecent /= float64(len(recent))
-		mathProgression = avgRecent
-	}
+	// Mathematical progression follows the smoothed level
+	mathProgression := frame.Envelope
 
 	centerX, centerY := width/2, height/2
 	basePhase := GetBasePhase()
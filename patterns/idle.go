@@ -0,0 +1,84 @@
+package patterns
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"milkshaker/audio"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type IdleStar struct {
+	x, y    float64
+	drift   float64
+	twinkle float64
+	char    rune
+}
+
+var (
+	// Slowly drifting star field
+	idleStars    []IdleStar
+	maxIdleStars = 40
+
+	idlePhase      float64 = 0.0
+	idleLastUpdate time.Time
+	idleWidth      int
+	idleHeight     int
+)
+
+// DrawIdle creates a calm screensaver shown while no audio is playing
+func DrawIdle(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	now := clock()
+	elapsed := now.Sub(idleLastUpdate).Seconds()
+	if elapsed > 0.5 {
+		// First frame after a break; don't jump
+		elapsed = 0
+	}
+	idleLastUpdate = now
+	idlePhase += elapsed * 0.15
+
+	width64, height64 := float64(width), float64(height)
+
+	// Scatter stars once, or again after the screen was resized
+	if len(idleStars) == 0 || width != idleWidth || height != idleHeight {
+		idleWidth, idleHeight = width, height
+		idleStars = idleStars[:0]
+		for i := 0; i < maxIdleStars; i++ {
+			idleStars = append(idleStars, IdleStar{
+				x:       rng.Float64() * width64,
+				y:       rng.Float64() * height64,
+				drift:   0.5 + rng.Float64()*1.5,
+				twinkle: rng.Float64() * 2 * math.Pi,
+				char:    []rune{'.', '·', '*', '+'}[rng.Intn(4)],
+			})
+		}
+	}
+
+	for i := range idleStars {
+		star := &idleStars[i]
+		star.x = math.Mod(star.x+star.drift*elapsed+width64, width64)
+
+		brightness := 0.25 + 0.2*math.Sin(idlePhase*4+star.twinkle)
+		hue := math.Mod(0.6+idlePhase*0.05, 1.0)
+		screen.SetContent(int(star.x), int(star.y), star.char, nil, tcell.StyleDefault.Foreground(HSVToRGB(hue, 0.4, brightness)))
+	}
+
+	// A single breathing line across the middle
+	breath := (math.Sin(idlePhase*2) + 1) / 2
+	centerY := height / 2
+	for x := 0; x < width; x++ {
+		offset := math.Sin(float64(x)*0.08+idlePhase*3) * (1 + breath)
+		y := centerY + int(math.Round(offset))
+		if y < 0 || y >= height {
+			continue
+		}
+		hue := math.Mod(0.55+float64(x)/width64*0.15+idlePhase*0.05, 1.0)
+		screen.SetContent(x, y, '~', nil, tcell.StyleDefault.Foreground(HSVToRGB(hue, 0.35, 0.25+breath*0.25)))
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -58,32 +60,17 @@ var (
 	// Rainbow wave effects
 	rainbowPhase float64 = 0.0
 	pulsePhase   float64 = 0.0
-
-	// Intensity tracking
-	peakHistory []float64
-	maxHistory  = 30
 )
 
 // DrawLogo creates an epic dynamic logo with particles, glitches, and rainbow effects
-func DrawLogo(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64) {
-	now := time.Now()
+func DrawLogo(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame) {
+	now := clock()
 	elapsed := now.Sub(logoLastUpdate).Seconds()
 	if elapsed < 1.0/60.0 { // 60 FPS limit
 		return
 	}
 	logoLastUpdate = now
-
-	// Track peak history for more responsive effects
-	peakHistory = append(peakHistory, peak)
-	if len(peakHistory) > maxHistory {
-		peakHistory = peakHistory[1:]
-	}
-
-	avgPeak := 0.0
-	for _, p := range peakHistory {
-		avgPeak += p
-	}
-	avgPeak /= float64(len(peakHistory))
+	peak := frame.Peak
 
 	logoFrames := []string{
 		" __    __     __     __         __  __     ______     __  __     ______     __  __     ______     ______    ",
@@ -262,7 +249,7 @@ func DrawLogo(screen tcell.Screen, width, height int, color tcell.Color, char ru
 	drawSparkles(screen, width, height)
 
 	// Draw glitch overlay effects
-	drawGlitchOverlay(screen, width, height)
+	drawGlitchOverlay(screen, width, height, rng)
 }
 
 func updateParticles(elapsed, peak float64, width, height int, rng *rand.Rand) {
@@ -416,7 +403,7 @@ func drawSparkles(screen tcell.Screen, width, height int) {
 	}
 }
 
-func drawGlitchOverlay(screen tcell.Screen, width, height int) {
+func drawGlitchOverlay(screen tcell.Screen, width, height int, rng *rand.Rand) {
 	// Additional glitch effects like random noise pixels
 	for _, glitch := range glitchBlocks {
 		if glitch.intensity > 0.3 {
@@ -425,13 +412,13 @@ func drawGlitchOverlay(screen tcell.Screen, width, height int) {
 
 			for dy := 0; dy < glitch.height; dy++ {
 				for dx := 0; dx < glitch.width; dx++ {
-					if rand.Float64() < 0.3 {
+					if rng.Float64() < 0.3 {
 						x := glitch.x + dx + glitch.offsetX
 						y := glitch.y + dy + glitch.offsetY
 
 						if x >= 0 && x < width && y >= 0 && y < height {
-							char := noiseChars[rand.Intn(len(noiseChars))]
-							hue := math.Mod(rainbowPhase*0.15+rand.Float64()*0.1, 1.0)
+							char := noiseChars[rng.Intn(len(noiseChars))]
+							hue := math.Mod(rainbowPhase*0.15+rng.Float64()*0.1, 1.0)
 							saturation := 0.4 + glitch.intensity*0.4
 							value := glitch.intensity * 0.7
 							color := HSVToRGB(hue, saturation, value)
@@ -446,7 +433,7 @@ func drawGlitchOverlay(screen tcell.Screen, width, height int) {
 }
 
 // DrawLogoLayer draws the logo as an integrated pattern layer with all the epic effects
-func DrawLogoLayer(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64, depthLayer int) {
+func DrawLogoLayer(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame, depthLayer int) {
 	// Show in multiple layers with different intensities
 	if depthLayer < 0 || depthLayer > 4 {
 		return
@@ -455,9 +442,13 @@ func DrawLogoLayer(screen tcell.Screen, width, height int, color tcell.Color, ch
 	// Reduce intensity for depth layers but keep effects
 	originalStrength := logoGradientStrength
 	depthScale := 1.0 - float64(depthLayer)*0.15
-	logoGradientStrength *= depthScale * (0.5 + peak*0.3)
+	logoGradientStrength *= depthScale * (0.5 + frame.Peak*0.3)
 
-	DrawLogo(screen, width, height, color, char, rng, peak*depthScale)
+	layer := frame
+	layer.Peak *= depthScale
+	layer.Envelope *= depthScale
+	layer.PeakHold *= depthScale
+	DrawLogo(screen, width, height, color, char, rng, layer)
 
 	// Restore original strength
 	logoGradientStrength = originalStrength
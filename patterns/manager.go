@@ -1,17 +1,32 @@
 package patterns
 
 import (
+	"math"
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
+const (
+	// Part of a beat, from its start, in which its low-band onset is measured
+	downbeatOnsetWindow = 0.25
+
+	// How far each bar moves the per-beat onset averages towards the latest bar
+	downbeatSmoothing = 0.25
+
+	// Another beat must have this much stronger onsets to take over as the downbeat
+	downbeatMargin = 1.1
+)
+
 // Visualizator represents a group of patterns that work together
 type Visualizator struct {
 	Name     string
-	Patterns []PatternFunc
-	Enabled  []bool // Which patterns in the group are currently enabled
+	Patterns []FramePatternFunc
+	Names    []string // Display name of each pattern
+	Enabled  []bool   // Which patterns in the group are currently enabled
 }
 
 // Manager handles visualizator selection and pattern drawing
@@ -22,6 +37,22 @@ type Manager struct {
 	rng             *rand.Rand
 	lastShuffleTime time.Time
 	shuffleDuration time.Duration
+
+	// Tempo sync for bar-aligned shuffling; bars start on the beat with the strongest
+	// low-band onsets, which is usually where the kick drum lands on beat 1
+	beatsPerBar   int
+	beatCount     int
+	lastBeatPhase float64
+	onBarBoundary bool
+	beatOnset     float64   // Strongest low-band energy early in the current beat
+	barOnsets     []float64 // Average low-band onset of each beat of the bar
+	downbeat      int       // Beat of the bar (beatCount modulo beatsPerBar) that starts bars
+
+	// Calm screensaver while no audio is playing
+	idle bool
+
+	// Colors following the dominant note
+	lastNoteUpdate time.Time
 }
 
 // NewManager creates a new pattern manager with predefined visualizators
@@ -29,28 +60,38 @@ func NewManager() *Manager {
 	visualizators := []Visualizator{
 		{
 			Name:     "Milkshaker",
-			Patterns: []PatternFunc{DrawLogo},
+			Patterns: []FramePatternFunc{DrawLogo},
+			Names:    []string{"Logo"},
 			Enabled:  []bool{true},
 		},
 		{
 			Name:     "Starburst",
-			Patterns: []PatternFunc{DrawStarburst},
+			Patterns: []FramePatternFunc{DrawStarburst},
+			Names:    []string{"Starburst"},
 			Enabled:  []bool{true},
 		},
 		{
 			Name:     "Fibonacci",
-			Patterns: []PatternFunc{DrawFibonacci},
+			Patterns: []FramePatternFunc{DrawFibonacci},
+			Names:    []string{"Fibonacci"},
 			Enabled:  []bool{true},
 		},
 		{
 			Name:     "Wave",
-			Patterns: []PatternFunc{DrawWave},
+			Patterns: []FramePatternFunc{DrawWave},
+			Names:    []string{"Wave"},
 			Enabled:  []bool{true},
 		},
 		{
-			Name:     "MixMax",
-			Patterns: []PatternFunc{DrawStarburst, DrawFibonacci, DrawWave, DrawLogo},
-			Enabled:  []bool{true, true, true, true},
+			Name: "MixMax",
+			Patterns: []FramePatternFunc{
+				DrawStarburst,
+				DrawFibonacci,
+				DrawWave,
+				DrawLogo,
+			},
+			Names:   []string{"Starburst", "Fibonacci", "Wave", "Logo"},
+			Enabled: []bool{true, true, true, true},
 		},
 	}
 
@@ -59,8 +100,10 @@ func NewManager() *Manager {
 		currentIndex:    0,
 		shuffleEnabled:  false,
 		rng:             rand.New(rand.NewSource(42)),
-		lastShuffleTime: time.Now(),
+		lastShuffleTime: clock(),
 		shuffleDuration: 27 * time.Second,
+		beatsPerBar:     4,
+		barOnsets:       make([]float64, 4),
 	}
 }
 
@@ -83,8 +126,87 @@ func (m *Manager) CycleVisualizator() {
 func (m *Manager) ToggleShuffle() {
 	m.shuffleEnabled = !m.shuffleEnabled
 	if m.shuffleEnabled {
-		m.lastShuffleTime = time.Now() // Reset timer when enabling shuffle
+		m.lastShuffleTime = clock() // Reset timer when enabling shuffle
+	}
+}
+
+// SetTempo feeds the current tempo, beat phase and low-band energy so shuffling and
+// patterns follow the music; the low band locates the downbeat
+func (m *Manager) SetTempo(bpm, phase, low float64) {
+	m.onBarBoundary = false
+
+	if bpm > 0 {
+		// The phase wrapping around means a new beat started
+		if phase < m.lastBeatPhase-0.5 {
+			m.endBeat()
+			m.beatCount++
+			m.onBarBoundary = m.beatCount%m.beatsPerBar == m.downbeat
+		}
+		if phase < downbeatOnsetWindow {
+			m.beatOnset = math.Max(m.beatOnset, low)
+		}
+	} else {
+		m.beatCount = 0
+		m.beatOnset = 0
+		m.downbeat = 0
+		for i := range m.barOnsets {
+			m.barOnsets[i] = 0
+		}
+	}
+	m.lastBeatPhase = phase
+
+	tempoBPM = bpm
+	beatPhase = phase
+}
+
+// SetIdle switches the calm idle visualizer on while no audio is playing
+func (m *Manager) SetIdle(idle bool) {
+	if m.idle && !idle {
+		// Don't shuffle straight away when the music comes back
+		m.lastShuffleTime = clock()
+	}
+	m.idle = idle
+}
+
+// IsIdle returns whether the idle visualizer is showing
+func (m *Manager) IsIdle() bool {
+	return m.idle
+}
+
+// ToggleNoteHue toggles mapping pattern colors to the note being played
+func (m *Manager) ToggleNoteHue() {
+	noteHueEnabled = !noteHueEnabled
+}
+
+// IsNoteHueEnabled returns whether pattern colors follow the note being played
+func (m *Manager) IsNoteHueEnabled() bool {
+	return noteHueEnabled
+}
+
+// updateNoteHue eases the shared note hue toward the dominant pitch class, holding it between notes
+func (m *Manager) updateNoteHue(frame audio.AudioFrame) {
+	now := clock()
+	elapsed := math.Min(now.Sub(m.lastNoteUpdate).Seconds(), 1.0)
+	m.lastNoteUpdate = now
+
+	if frame.Silent {
+		noteHue = -1
+		return
+	}
+	if frame.PitchClass < 0 {
+		return
+	}
+
+	target := NoteHue(frame.PitchClass)
+	if noteHue < 0 {
+		noteHue = target
+		return
 	}
+
+	// Take the short way around the color wheel
+	diff := target - noteHue
+	diff -= math.Round(diff)
+	noteHue = math.Mod(noteHue+diff*(1-math.Exp(-elapsed/0.3))+1, 1.0)
 }
 
 // IsShuffleEnabled returns whether shuffle is currently enabled
@@ -137,28 +259,64 @@ func (m *Manager) ShuffleCurrentVisualizator() {
 	}
 }
 
+// endBeat adds the onset of the beat that just ended to its place in the bar and
+// moves the downbeat if another beat has clearly stronger onsets
+func (m *Manager) endBeat() {
+	slot := m.beatCount % m.beatsPerBar
+	m.barOnsets[slot] += (m.beatOnset - m.barOnsets[slot]) * downbeatSmoothing
+	m.beatOnset = 0
+
+	strongest := m.downbeat
+	for i, onset := range m.barOnsets {
+		if onset > m.barOnsets[strongest] {
+			strongest = i
+		}
+	}
+	if m.barOnsets[strongest] > m.barOnsets[m.downbeat]*downbeatMargin {
+		m.downbeat = strongest
+	}
+}
+
 // DrawCurrentVisualizator draws all enabled patterns in the current visualizator
-func (m *Manager) DrawCurrentVisualizator(screen tcell.Screen, color tcell.Color, rng *rand.Rand, amplitude float64) {
+func (m *Manager) DrawCurrentVisualizator(screen tcell.Screen, color tcell.Color, rng *rand.Rand, frame audio.AudioFrame) {
 	if m.currentIndex < 0 || m.currentIndex >= len(m.visualizators) {
 		return
 	}
 
+	// Keep shuffling and pattern animations in time with the music
+	m.SetTempo(frame.Tempo, frame.BeatPhase, frame.Band(audio.BandSubBass)+frame.Band(audio.BandBass))
+	m.updateNoteHue(frame)
+
 	width, height := screen.Size()
 	char := RandomRune(rng)
 	current := m.visualizators[m.currentIndex]
 
-	// Auto-shuffle: cycle visualizators every 27 seconds when shuffle is enabled
+	if m.idle {
+		DrawIdle(screen, width, height, color, char, rng, frame)
+		return
+	}
+
+	// Auto-shuffle: cycle visualizators every 27 seconds when shuffle is enabled,
+	// waiting for the next bar boundary (at most one extra bar) when the tempo is known
 	if m.shuffleEnabled {
-		if time.Since(m.lastShuffleTime) >= m.shuffleDuration {
-			m.CycleVisualizator()
-			m.lastShuffleTime = time.Now()
+		sinceShuffle := clock().Sub(m.lastShuffleTime)
+		if sinceShuffle >= m.shuffleDuration {
+			barLength := time.Duration(0)
+			if tempoBPM > 0 {
+				barLength = time.Duration(float64(m.beatsPerBar) * 60 / tempoBPM * float64(time.Second))
+			}
+
+			if barLength == 0 || m.onBarBoundary || sinceShuffle >= m.shuffleDuration+barLength {
+				m.CycleVisualizator()
+				m.lastShuffleTime = clock()
+			}
 		}
 	}
 
 	// Draw all enabled patterns
 	for i, pattern := range current.Patterns {
 		if i < len(current.Enabled) && current.Enabled[i] {
-			pattern(screen, width, height, color, char, rng, amplitude)
+			pattern(screen, width, height, color, char, rng, frame)
 		}
 	}
 }
@@ -227,36 +385,14 @@ func (m *Manager) GetCurrentPatternNames() []string {
 	current := m.visualizators[m.currentIndex]
 	names := make([]string, len(current.Patterns))
 
-	for i, pattern := range current.Patterns {
-		// Simple name mapping based on function comparison
-		// This is hacky but functional for our purposes
-		switch {
-		case isSameFunction(pattern, DrawStarburst):
-			names[i] = "Starburst"
-		case isSameFunction(pattern, DrawFibonacci):
-			names[i] = "Fibonacci"
-		case isSameFunction(pattern, DrawLogo):
-			names[i] = "Logo"
-		case isSameFunction(pattern, DrawWave):
-			names[i] = "Wave"
-		default:
-			names[i] = "Unknown"
-		}
-	}
-
-	// Fallback to generic names if function comparison fails
-	for i, name := range names {
-		if name == "Unknown" {
+	for i := range current.Patterns {
+		if i < len(current.Names) && current.Names[i] != "" {
+			names[i] = current.Names[i]
+		} else {
+			// Fallback to generic names for unnamed patterns
 			names[i] = "Pattern " + string(rune('A'+i))
 		}
 	}
 
 	return names
 }
-
-// Helper function to compare functions (basic implementation)
-func isSameFunction(f1, f2 PatternFunc) bool {
-	// This is a simple comparison - in a real implementation you might want
-	// a more robust way to identify functions
-	return &f1 == &f2
-}
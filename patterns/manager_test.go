@@ -0,0 +1,73 @@
+package patterns
+
+import "testing"
+
+func TestBarsStartOnTheDownbeat(t *testing.T) {
+	// The kick is loudest on one beat of every four; count beats from the tempo lock
+	tests := []struct {
+		name     string
+		downbeat int // Beat, counted from the lock, with the strongest low-band onset
+	}{
+		{"locked on beat 1", 0},
+		{"locked on beat 2", 1},
+		{"locked on beat 3", 2},
+		{"locked on beat 4", 3},
+	}
+	for _, test := range tests {
+		m := NewManager()
+
+		// 120 BPM drawn at 60 FPS: 30 frames per beat
+		const framesPerBeat = 30
+		var boundaries []int
+		for frame := 0; frame < 16*4*framesPerBeat; frame++ {
+			beat, position := frame/framesPerBeat, frame%framesPerBeat
+			low := 0.0
+			if position < 3 {
+				low = 0.4
+				if beat%4 == test.downbeat {
+					low = 1.0
+				}
+			}
+			m.SetTempo(120, float64(position)/framesPerBeat, low)
+			if m.onBarBoundary {
+				boundaries = append(boundaries, beat)
+			}
+		}
+
+		// Allow a few bars to find the downbeat, then every bar must start on it
+		settled := 0
+		for _, beat := range boundaries {
+			if beat < 4*4 {
+				continue
+			}
+			settled++
+			if beat%4 != test.downbeat {
+				t.Errorf("%s: bar started on beat %d, want beats %d modulo 4", test.name, beat, test.downbeat)
+				break
+			}
+		}
+		if settled != 12 {
+			t.Errorf("%s: %d bar boundaries in the last 12 bars, want 12", test.name, settled)
+		}
+	}
+}
+
+func TestBarsResetWhenTempoIsLost(t *testing.T) {
+	m := NewManager()
+	for beat := 0; beat < 32; beat++ {
+		low := 0.2
+		if beat%4 == 1 {
+			low = 1.0
+		}
+		m.SetTempo(120, 0, low)
+		m.SetTempo(120, 0.9, 0)
+	}
+	if m.downbeat != 1 {
+		t.Fatalf("downbeat = %d, want 1", m.downbeat)
+	}
+
+	m.SetTempo(0, 0, 0)
+	if m.downbeat != 0 || m.beatCount != 0 {
+		t.Errorf("after losing the tempo: downbeat %d, beat count %d, want 0 and 0", m.downbeat, m.beatCount)
+	}
+}
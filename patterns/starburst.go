@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -76,44 +78,21 @@ var (
 	// spiralPhase         float64 = 0.0
 	shockwavePhase      float64 = 0.0
 	starburstLastUpdate time.Time
-
-	// Peak tracking for better responsiveness
-	starPeakHistory []float64
-	maxStarHistory  = 20
 )
 
 // DrawStarburst creates an EPIC explosive starburst with lightning, particles, and shockwaves
-func DrawStarburst(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64) {
-	now := time.Now()
+func DrawStarburst(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame) {
+	now := clock()
 	elapsed := now.Sub(starburstLastUpdate).Seconds()
 	if elapsed < 1.0/240.0 { // 240 FPS limit
 		return
 	}
 	starburstLastUpdate = now
 
-	// Track peak history for explosive effects
-	starPeakHistory = append(starPeakHistory, peak)
-	if len(starPeakHistory) > maxStarHistory {
-		starPeakHistory = starPeakHistory[1:]
-	}
+	peak := frame.Peak
 
-	// Calculate peak derivatives for explosion detection
-	peakMomentum := 0.0
-	if len(starPeakHistory) > 5 {
-		recent := starPeakHistory[len(starPeakHistory)-3:]
-		old := starPeakHistory[len(starPeakHistory)-6 : len(starPeakHistory)-3]
-		recentAvg := 0.0
-		oldAvg := 0.0
-		for _, p := range recent {
-			recentAvg += p
-		}
-		for _, p := range old {
-			oldAvg += p
-		}
-		recentAvg /= float64(len(recent))
-		oldAvg /= float64(len(old))
-		peakMomentum = recentAvg - oldAvg
-	}
+	// Peak rising above its envelope drives the explosions
+	peakMomentum := peak - frame.Envelope
 
 	centerX, centerY := width/2, height/2
 	basePhase := GetBasePhase()
@@ -714,6 +693,10 @@ func drawEnergyRings(screen tcell.Screen, centerX, centerY int, maxRadius, peak,
 		// Ring animation phase
 		ringPhase := basePhase*0.8 + float64(ring)*0.3
 		energyPulse := 1.0 + math.Sin(ringPhase*3.0)*0.4*peak
+		if phase, locked := GetBeatPhase(); locked {
+			// Pulse outwards on every beat, rings slightly delayed from the center
+			energyPulse = 1.0 + math.Cos(2*math.Pi*(phase-float64(ring)*0.05))*0.4*peak
+		}
 
 		finalIntensity := ringIntensity * energyPulse
 
@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"time"
 
+	"milkshaker/audio"
+
 	"github.com/gdamore/tcell/v2"
 )
 
@@ -54,33 +56,21 @@ var (
 	liquidPhase    float64 = 0.0
 	ripplePhase    float64 = 0.0
 	waveLastUpdate time.Time
-
-	// Peak tracking
-	wavePeakHistory []float64
-	maxWaveHistory  = 9
 )
 
 // DrawWave creates a minimalistic yet epic flowing liquid wave experience
-func DrawWave(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, peak float64) {
-	now := time.Now()
+func DrawWave(screen tcell.Screen, width, height int, color tcell.Color, char rune, rng *rand.Rand, frame audio.AudioFrame) {
+	now := clock()
 	elapsed := now.Sub(waveLastUpdate).Seconds()
 	if elapsed < 1.0/520.0 { // 520 FPS limit
 		return
 	}
 	waveLastUpdate = now
 
-	// Track peak history for smooth responsiveness
-	wavePeakHistory = append(wavePeakHistory, peak)
-	if len(wavePeakHistory) > maxWaveHistory {
-		wavePeakHistory = wavePeakHistory[1:]
-	}
+	peak := frame.Peak
 
-	// Calculate smooth peak average
-	avgPeak := 0.0
-	for _, p := range wavePeakHistory {
-		avgPeak += p
-	}
-	avgPeak /= float64(len(wavePeakHistory))
+	// Smooth peak for slow, flowing responsiveness
+	avgPeak := frame.Envelope
 
 	// Update phases with slow, meditative audio reactivity
 	speedMultiplier := 0.3 + avgPeak*0.8 + peak*0.4
@@ -90,7 +80,7 @@ func DrawWave(screen tcell.Screen, width, height int, color tcell.Color, char ru
 
 	// Update systems
 	updateWaveParticles(elapsed, peak, avgPeak, width, height, rng)
-	updateRipples(elapsed, peak, avgPeak, width, height, rng)
+	updateRipples(elapsed, peak, avgPeak, frame.Stereo.Balance, width, height, rng)
 	updateFlowField(elapsed, peak, width, height)
 
 	// Draw main liquid waves
@@ -323,11 +313,16 @@ func drawWaveParticles(screen tcell.Screen, width, height int) {
 	}
 }
 
-func updateRipples(elapsed, peak, avgPeak float64, width, height int, rng *rand.Rand) {
+func updateRipples(elapsed, peak, avgPeak, balance float64, width, height int, rng *rand.Rand) {
 	// Create minimal ripples to keep focus on wave lines
 	if len(ripples) < maxRipples && rng.Float64() < peak*0.3*elapsed {
+		// Spawn on the louder side; a centered mix spreads across the whole width
+		center := 0.5 + balance*0.35
+		spread := 0.5 - math.Abs(balance)*0.3
+		spawnX := math.Max(0, math.Min(1, center+(rng.Float64()-0.5)*2*spread))
+
 		ripple := Ripple{
-			x:         rng.Float64() * float64(width),
+			x:         spawnX * float64(width),
 			y:         float64(height/2) + (rng.Float64()-0.5)*float64(height/8),
 			radius:    1.0,
 			maxRadius: 12.0 + peak*15.0,
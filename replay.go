@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"milkshaker/audio"
+	"milkshaker/patterns"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// replayOptions holds the settings of the replay command
+type replayOptions struct {
+	loop         bool
+	visualizator int
+	noteColors   bool
+	headless     bool
+	width        int
+	height       int
+	fps          int
+	snapshot     string
+}
+
+// replaySession draws a recorded feature log instead of live audio
+func replaySession(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	o := &replayOptions{}
+	fs.BoolVar(&o.loop, "loop", false, "restart the replay when it ends")
+	fs.IntVar(&o.visualizator, "visualizator", 1, "visualizator to show, numbered as in the status line")
+	fs.BoolVar(&o.noteColors, "note-colors", false, "start with pattern colors following the note being played")
+	fs.BoolVar(&o.headless, "headless", false, "render off-screen as fast as possible and print a fingerprint of all frames (for CI)")
+	size := fs.String("size", "120x40", "screen size in cells for --headless")
+	fs.IntVar(&o.fps, "fps", 60, "frames per second drawn along the recorded timeline in --headless mode")
+	fs.StringVar(&o.snapshot, "snapshot", "", "with --headless, write the last frame as text to this file")
+	files := parseArgs(fs, args)
+
+	if len(files) != 1 {
+		fmt.Println("Usage: go run . replay <session.jsonl> [--loop] [--visualizator N] [--headless [--size 120x40] [--fps 60] [--snapshot frame.txt]]")
+		os.Exit(1)
+	}
+	if _, err := fmt.Sscanf(*size, "%dx%d", &o.width, &o.height); err != nil || o.width <= 0 || o.height <= 0 {
+		log.Fatalf("Invalid --size %q, expected e.g. 120x40", *size)
+	}
+	if o.fps <= 0 {
+		log.Fatalf("Invalid --fps %d", o.fps)
+	}
+
+	records, err := audio.LoadFeatureLog(files[0])
+	if err != nil {
+		log.Fatalf("Failed to load feature log: %v", err)
+	}
+
+	if o.headless {
+		if err := replayHeadless(records, o); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+	replayInteractive(files[0], records, o)
+}
+
+// newReplayManager creates a pattern manager set up as requested
+func newReplayManager(o *replayOptions) *patterns.Manager {
+	manager := patterns.NewManager()
+	manager.SetVisualizator(o.visualizator - 1)
+	if o.noteColors != manager.IsNoteHueEnabled() {
+		manager.ToggleNoteHue()
+	}
+	return manager
+}
+
+// replayHeadless steps through the recorded timeline on a simulated screen with a
+// virtual clock and fixed random seeds, so every run draws exactly the same frames
+func replayHeadless(records []audio.FeatureRecord, o *replayOptions) error {
+	start := time.Unix(0, 0)
+	now := start
+	patterns.SetClock(func() time.Time { return now })
+	defer patterns.SetClock(nil)
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to create screen: %v", err)
+	}
+	defer screen.Fini()
+	screen.SetSize(o.width, o.height)
+
+	manager := newReplayManager(o)
+	replay := audio.NewFeatureReplay(records, start)
+	// Round up so frames are never closer than the patterns' own 60 FPS limit
+	frameDuration := (time.Second + time.Duration(o.fps) - 1) / time.Duration(o.fps)
+	fingerprint := fnv.New64a()
+	var cellBytes []byte
+
+	frames := 0
+	for ; ; now = now.Add(frameDuration) {
+		frame := replay.Frame(now)
+		manager.SetIdle(frame.Silent)
+
+		screen.Clear()
+		manager.DrawCurrentVisualizator(screen, tcell.ColorWhite, rand.New(rand.NewSource(int64(frames))), frame)
+		screen.Show()
+		frames++
+
+		// Hash characters and colors of every cell
+		cells, _, _ := screen.GetContents()
+		cellBytes = cellBytes[:0]
+		for _, cell := range cells {
+			fg, bg, _ := cell.Style.Decompose()
+			cellBytes = append(cellBytes, string(cell.Runes)...)
+			cellBytes = append(cellBytes, 0, byte(fg), byte(fg>>8), byte(fg>>16), byte(bg), byte(bg>>8), byte(bg>>16))
+		}
+		fingerprint.Write(cellBytes)
+
+		if replay.Done(now) {
+			break
+		}
+	}
+
+	fmt.Printf("Replayed %d records (%.1fs) as %d frames at %dx%d\n", len(records), replay.Duration().Seconds(), frames, o.width, o.height)
+	fmt.Printf("Fingerprint: %016x\n", fingerprint.Sum64())
+
+	if o.snapshot != "" {
+		if err := os.WriteFile(o.snapshot, []byte(screenText(screen)), 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+// screenText renders the characters of a simulated screen, one line per row
+func screenText(screen tcell.SimulationScreen) string {
+	cells, width, height := screen.GetContents()
+
+	var text strings.Builder
+	for y := 0; y < height; y++ {
+		line := make([]rune, 0, width)
+		for x := 0; x < width; x++ {
+			r := ' '
+			if runes := cells[y*width+x].Runes; len(runes) > 0 && runes[0] != 0 {
+				r = runes[0]
+			}
+			line = append(line, r)
+		}
+		text.WriteString(strings.TrimRight(string(line), " "))
+		text.WriteString("\n")
+	}
+	return text.String()
+}
+
+// replayInteractive shows the replay in the terminal at the original pacing
+func replayInteractive(path string, records []audio.FeatureRecord, o *replayOptions) {
+	manager := newReplayManager(o)
+	start := time.Now()
+	replayStart := start
+	replay := audio.NewFeatureReplay(records, start)
+
+	app := tview.NewApplication()
+
+	fullScreenVisualizer := tview.NewBox().SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		now := time.Now()
+		if o.loop && replay.Done(now) {
+			replayStart = now
+			replay.Restart(now)
+		}
+		frame := replay.Frame(now)
+		manager.SetIdle(frame.Silent)
+
+		rng := rand.New(rand.NewSource(now.UnixNano()))
+		manager.DrawCurrentVisualizator(screen, tcell.ColorWhite, rng, frame)
+
+		elapsed := min(now.Sub(replayStart), replay.Duration())
+		info := fmt.Sprintf("Replay: %s | %.1fs / %.1fs", path, elapsed.Seconds(), replay.Duration().Seconds())
+		visualizer := fmt.Sprintf("Visualizator: %s (%d/%d)", manager.GetCurrentVisualizatorName(), manager.GetCurrentVisualizatorIndex()+1, manager.GetVisualizatorCount())
+		tview.Print(screen, info, x, y, width, tview.AlignCenter, tcell.ColorWhite)
+		tview.Print(screen, visualizer, x, y+1, width, tview.AlignCenter, tcell.ColorWhite)
+		tview.Print(screen, "N (Note colors) | P (Patterns) | X (Shuffle) | Ctrl+C (Quit)", x, height-1, width, tview.AlignCenter, tcell.ColorGreenYellow)
+
+		return x, y, width, height
+	})
+
+	go func() {
+		for {
+			time.Sleep(time.Second / 60) // 60 FPS
+			if !o.loop && time.Since(start) > replay.Duration()+time.Second {
+				// Hold the last frame for a moment, then quit
+				app.Stop()
+				return
+			}
+			app.Draw()
+		}
+	}()
+
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'n', 'N':
+			manager.ToggleNoteHue()
+		case 'p', 'P':
+			manager.CycleVisualizator()
+		case 'x', 'X':
+			manager.ToggleShuffle()
+		}
+
+		if event.Key() == tcell.KeyCtrlC {
+			app.Stop()
+		}
+		return event
+	})
+
+	if err := app.SetRoot(fullScreenVisualizer, true).SetFocus(fullScreenVisualizer).Run(); err != nil {
+		fmt.Printf("\nReplay stopped: %v\n", err)
+	}
+}